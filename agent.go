@@ -4,74 +4,102 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	GitHubTokenHeader         = "X-Github-Token"
 	PublicKeyIdentifierHeader = "Github-Public-Key-Identifier"
 	PublicKeySignatureHeader  = "Github-Public-Key-Signature"
+	// CopilotIntegrationIDHeader identifies the client (integration) the request
+	// was made from.
+	CopilotIntegrationIDHeader = "Copilot-Integration-Id"
 )
 
 type Agent interface {
 	Execute(ctx context.Context, token string, req *Request, w http.ResponseWriter) error
 }
 
+// AgentHandler returns a http.HandlerFunc that verifies the request payload with
+// v and executes the agent. It is the same as NewAgentHandler with the default
+// options.
 func AgentHandler(v PayloadVerifier, a Agent) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		_ = getRequiredHeader(r, PublicKeyIdentifierHeader)
-		signature := getRequiredHeader(r, PublicKeySignatureHeader)
+	return NewAgentHandler(v, a).ServeHTTP
+}
 
-		b, err := io.ReadAll(r.Body)
-		if err != nil {
-			fmt.Println(fmt.Errorf("failed to read request body: %w", err))
-			w.WriteHeader(http.StatusInternalServerError)
+// NewAgentHandler returns a http.Handler that verifies the request payload with
+// v, decodes the request, and executes the agent.
+//
+// Requests with missing headers or an invalid signature are rejected with
+// 400 Bad Request or 401 Unauthorized before the agent is executed. If the agent
+// returns an error before it writes to the response, the request is rejected with
+// 500 Internal Server Error; after it has started writing (streaming), the error is
+// only logged and reported to the error handler.
+func NewAgentHandler(v PayloadVerifier, a Agent, opts ...HandlerOption) http.Handler {
+	o := newHandlerOptions(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, reqSpan := o.tracer.Start(r.Context(), "copilot.agent")
+		defer reqSpan.End()
+
+		r = o.withLogger(r.WithContext(ctx))
+
+		o.metrics.RequestReceived("agent")
+
+		b, ok := o.verify(v, w, r)
+		if !ok {
 			return
 		}
-		defer r.Body.Close()
 
-		isValid, err := v.Verify(b, signature)
+		token, err := getRequiredHeader(r, GitHubTokenHeader)
 		if err != nil {
-			fmt.Printf("failed to validate payload signature: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			o.fail(w, r, http.StatusBadRequest, err)
 			return
 		}
-		if !isValid {
-			http.Error(w, "invalid payload signature", http.StatusUnauthorized)
-			return
-		}
-
-		ctx := r.Context()
-
-		token := getRequiredHeader(r, GitHubTokenHeader)
-		ctx = AddGetHubToken(ctx, token)
 
+		_, span := o.tracer.Start(r.Context(), "copilot.decode")
 		var req Request
-		if err := json.Unmarshal(b, &req); err != nil {
-			fmt.Printf("failed to unmarshal request: %v\n", err)
-			w.WriteHeader(http.StatusBadRequest)
+		err = json.Unmarshal(b, &req)
+		endSpan(span, err)
+		if err != nil {
+			o.fail(w, r, http.StatusBadRequest, fmt.Errorf("failed to unmarshal request: %w", err))
 			return
 		}
 
-		session, err := req.GetSessionInfo()
+		r = withLogAttrs(r, slog.String("thread_id", req.ThreadID), slog.String("agent", req.Agent))
+
+		ctx = AddGetHubToken(r.Context(), token)
+		ctx = AddMetrics(ctx, o.metrics)
+
+		sctx, span := o.tracer.Start(ctx, "copilot.session")
+		session, err := req.GetSessionInfoContext(sctx)
+		endSpan(span, err)
 		if err != nil {
-			fmt.Println("error getting session context: ", err)
+			GetLogger(ctx).WarnContext(ctx, "error getting session context", "error", err)
 		}
 
 		ctx = AddSessionInfo(ctx, session)
 
-		if err := a.Execute(ctx, token, &req, w); err != nil {
-			fmt.Printf("failed to execute agent: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-		}
-	}
-}
+		ctx, span = o.tracer.Start(ctx, "copilot.execute", trace.WithAttributes(
+			attribute.String(AttrThreadID, req.ThreadID),
+			attribute.String(AttrAgent, req.Agent),
+		))
+
+		rw := &responseWriter{ResponseWriter: w, metrics: o.metrics}
 
-func getRequiredHeader(r *http.Request, key string) string {
-	value := r.Header.Get(key)
-	if value == "" {
-		panic(fmt.Errorf("missing required header %s", key))
-	}
-	return value
+		start := time.Now()
+		err = a.Execute(ctx, token, &req, rw)
+		rw.setStreamAttributes(span, start)
+		rw.recordStreamMetrics(start)
+		endSpan(span, err)
+		if err != nil {
+			o.metrics.ExecuteError("agent")
+			o.fail(rw, r, http.StatusInternalServerError, fmt.Errorf("failed to execute agent: %w", err))
+		}
+	})
 }
@@ -0,0 +1,87 @@
+package copilot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// acceptVerifier is a PayloadVerifier that accepts every payload.
+type acceptVerifier struct{}
+
+func (acceptVerifier) Verify([]byte, string) (bool, error) { return true, nil }
+
+// agentFunc adapts a function to the Agent interface.
+type agentFunc func(ctx context.Context, token string, req *Request, w http.ResponseWriter) error
+
+func (f agentFunc) Execute(ctx context.Context, token string, req *Request, w http.ResponseWriter) error {
+	return f(ctx, token, req, w)
+}
+
+func TestAgentHandlerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	agent := agentFunc(func(ctx context.Context, token string, req *Request, w http.ResponseWriter) error {
+		for _, delta := range []string{"Hel", "lo"} {
+			if _, err := w.Write([]byte("data: " + delta + "\n\n")); err != nil {
+				return err
+			}
+			w.(StreamEventRecorder).RecordStreamEvent(StreamEventDelta)
+		}
+		return nil
+	})
+	h := NewAgentHandler(acceptVerifier{}, agent, WithTracerProvider(tp))
+
+	body := `{"copilot_thread_id":"thread","agent":"test","messages":[{"role":"user","content":"Hi"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set(GitHubTokenHeader, "token")
+	r.Header.Set(PublicKeyIdentifierHeader, "key")
+	r.Header.Set(PublicKeySignatureHeader, "signature")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+
+	root, ok := spans["copilot.agent"]
+	if !ok {
+		t.Fatal("missing copilot.agent span")
+	}
+	for _, name := range []string{"copilot.verify", "copilot.decode", "copilot.session", "copilot.execute"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("missing %s span", name)
+			continue
+		}
+		if s.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("%s span isn't a child of the copilot.agent span", name)
+		}
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range spans["copilot.execute"].Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if got := attrs[AttrThreadID].AsString(); got != "thread" {
+		t.Errorf("%s = %q, want %q", AttrThreadID, got, "thread")
+	}
+	if got := attrs[AttrStreamDeltas].AsInt64(); got != 2 {
+		t.Errorf("%s = %d, want 2", AttrStreamDeltas, got)
+	}
+	if _, ok := attrs[AttrStreamFirstByteMilli]; !ok {
+		t.Errorf("missing %s attribute", AttrStreamFirstByteMilli)
+	}
+}
@@ -1,15 +1,21 @@
 package copilot
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/colbylwilliams/copilot-go/jsonschema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // CopilotModel represents the model to use for completions.
+//
+// The constants below are well-known models; the models available to a token
+// can be listed with Client.Models.
 type CopilotModel string
 
 const (
@@ -20,11 +26,146 @@ const (
 )
 
 // CompletionsRequest is a request to the Copilot API to get completions.
+//
+// The sampling and control parameters are pointers, so that only the ones that
+// are set are sent, and the model's defaults apply to the others. Use Ptr to set
+// them:
+//
+//	req.Temperature = copilot.Ptr[float32](0.2)
 type CompletionsRequest struct {
 	Model    CopilotModel       `json:"model" default:"gpt-4o"`
 	Messages []*Message         `json:"messages"`
 	Tools    []*CompletionsTool `json:"tools,omitempty"`
 	Stream   bool               `json:"stream"`
+
+	Temperature       *float32        `json:"temperature,omitempty"`
+	TopP              *float32        `json:"top_p,omitempty"`
+	MaxTokens         *int32          `json:"max_tokens,omitempty"`
+	Stop              []string        `json:"stop,omitempty"`
+	PresencePenalty   *float32        `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float32        `json:"frequency_penalty,omitempty"`
+	N                 *int            `json:"n,omitempty"`
+	Seed              *int64          `json:"seed,omitempty"`
+	ToolChoice        *ToolChoice     `json:"tool_choice,omitempty"`
+	ParallelToolCalls *bool           `json:"parallel_tool_calls,omitempty"`
+	ResponseFormat    *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// NewCompletionsRequest returns a CompletionsRequest for the model with the
+// messages and the sampling parameters (temperature, top_p, max_tokens, stop,
+// presence_penalty and frequency_penalty) of the agent request r, so the user's
+// settings are kept when the agent calls the Copilot API.
+//
+// A parameter of r with its zero value is considered unset, and isn't sent,
+// unless r was decoded from JSON that had the parameter, such as a temperature
+// of 0.
+func NewCompletionsRequest(model CopilotModel, r *Request) CompletionsRequest {
+	c := CompletionsRequest{
+		Model:    model,
+		Messages: append([]*Message(nil), r.Messages...),
+		Stop:     append([]string(nil), r.Stop...),
+	}
+	if r.has("temperature", r.Temperature == 0) {
+		c.Temperature = Ptr(r.Temperature)
+	}
+	if r.has("top_p", r.TopP == 0) {
+		c.TopP = Ptr(r.TopP)
+	}
+	if r.has("max_tokens", r.MaxTokens == 0) {
+		c.MaxTokens = Ptr(r.MaxTokens)
+	}
+	if r.has("presence_penalty", r.PresencePenalty == 0) {
+		c.PresencePenalty = Ptr(r.PresencePenalty)
+	}
+	if r.has("frequency_penalty", r.FrequencyPenalty == 0) {
+		c.FrequencyPenalty = Ptr(r.FrequencyPenalty)
+	}
+	return c
+}
+
+// Ptr returns a pointer to v, to set the optional parameters of a CompletionsRequest.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Tool choice modes of a ToolChoice.
+const (
+	ToolChoiceModeAuto     = "auto"
+	ToolChoiceModeNone     = "none"
+	ToolChoiceModeRequired = "required"
+)
+
+// ToolChoice controls which tool, if any, the model calls. It's either a mode
+// (auto, none or required) or the name of a function the model must call.
+type ToolChoice struct {
+	// Mode is the tool choice mode, if Function is empty.
+	Mode string
+	// Function is the name of the function the model must call.
+	Function string
+}
+
+// ToolChoiceFunction returns a ToolChoice that forces the model to call the
+// function with the given name.
+func ToolChoiceFunction(name string) *ToolChoice {
+	return &ToolChoice{Function: name}
+}
+
+// MarshalJSON marshals the ToolChoice as a mode string, or as a function object
+// if Function is set.
+func (t ToolChoice) MarshalJSON() ([]byte, error) {
+	if t.Function == "" {
+		return json.Marshal(t.Mode)
+	}
+	return json.Marshal(toolChoiceFunction{
+		Type:     "function",
+		Function: toolChoiceFunctionName{Name: t.Function},
+	})
+}
+
+// UnmarshalJSON unmarshals a ToolChoice from a mode string or a function object.
+func (t *ToolChoice) UnmarshalJSON(b []byte) error {
+	*t = ToolChoice{}
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &t.Mode)
+	}
+	var f toolChoiceFunction
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	t.Function = f.Function.Name
+	return nil
+}
+
+type toolChoiceFunction struct {
+	Type     string                 `json:"type"`
+	Function toolChoiceFunctionName `json:"function"`
+}
+
+type toolChoiceFunctionName struct {
+	Name string `json:"name"`
+}
+
+// Response format types of a ResponseFormat.
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat is the format of the model's response.
+type ResponseFormat struct {
+	// Type is the format type: text, json_object or json_schema.
+	Type string `json:"type"`
+	// JSONSchema is the schema of the response, if Type is json_schema.
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat is the JSON schema the model's response must conform to.
+type JSONSchemaFormat struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description,omitempty"`
+	Schema      jsonschema.Definition `json:"schema"`
+	Strict      bool                  `json:"strict,omitempty"`
 }
 
 // CompletionsTool represents a tool to use for completions.
@@ -33,36 +174,128 @@ type CompletionsTool struct {
 	Function ToolFunctionDefinition `json:"function"`
 }
 
-const endpoint = "https://api.githubcopilot.com/chat/completions"
+// CompletionsResponse is a (non-streamed) response from the Copilot API to get completions.
+type CompletionsResponse struct {
+	ID                string              `json:"id"`
+	Object            string              `json:"object,omitempty" default:"chat.completion"`
+	Created           int64               `json:"created"`
+	Model             string              `json:"model"`
+	SystemFingerprint string              `json:"system_fingerprint,omitempty"`
+	Choices           []CompletionsChoice `json:"choices"`
+	Usage             *CompletionsUsage   `json:"usage,omitempty"`
+
+	PromptFilterResults []*PromptFilterResult `json:"prompt_filter_results,omitempty"`
+}
+
+// CompletionsChoice is a choice in a CompletionsResponse.
+type CompletionsChoice struct {
+	Index                int64                 `json:"index"`
+	Message              *Message              `json:"message"`
+	FinishReason         string                `json:"finish_reason"`
+	Logprobs             *Logprobs             `json:"logprobs,omitempty"`
+	ContentFilterResults *ContentFilterResults `json:"content_filter_results,omitempty"`
+}
+
+// CompletionsUsage is the number of tokens used by a completions request.
+type CompletionsUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Message returns the message of the first choice, or nil if the response
+// doesn't have any choices.
+func (r *CompletionsResponse) Message() *Message {
+	if len(r.Choices) == 0 {
+		return nil
+	}
+	return r.Choices[0].Message
+}
+
+// FinishReason returns the finish reason of the first choice, or an empty string
+// if the response doesn't have any choices.
+func (r *CompletionsResponse) FinishReason() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].FinishReason
+}
 
 // ChatCompletionsStream is a convenience function that sets the Stream field to true
 // and calls ChatCompletions.
+//
+// Deprecated: Use Client.ChatCompletionsStream instead.
 func ChatCompletionsStream(ctx context.Context, token string, r CompletionsRequest, w io.Writer) (io.ReadCloser, error) {
 	r.Stream = true
 	return ChatCompletions(ctx, token, r, w)
 }
 
 // ChatCompletions sends a request to the the Copilot API to get completions.
+// The writer w is not used.
+//
+// Deprecated: Use Client.ChatCompletions instead.
 func ChatCompletions(ctx context.Context, token string, r CompletionsRequest, w io.Writer) (io.ReadCloser, error) {
+	return NewClient(WithTokenSource(StaticToken(token))).ChatCompletions(ctx, r)
+}
 
-	b, err := json.Marshal(r)
+// ChatCompletionsStream is a convenience method that sets the Stream field to true
+// and calls ChatCompletions.
+func (c *Client) ChatCompletionsStream(ctx context.Context, r CompletionsRequest) (io.ReadCloser, error) {
+	r.Stream = true
+	return c.ChatCompletions(ctx, r)
+}
+
+// Completions sets the Stream field to false, sends a request to the Copilot API
+// to get completions, and returns the decoded response.
+func (c *Client) Completions(ctx context.Context, r CompletionsRequest) (*CompletionsResponse, error) {
+	r.Stream = false
+
+	body, err := c.ChatCompletions(ctx, r)
 	if err != nil {
 		return nil, err
 	}
+	defer body.Close()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
-	if err != nil {
-		return nil, err
+	var res CompletionsResponse
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode completions response: %w", err)
+	}
+
+	return &res, nil
+}
+
+// completionsPath is the path of the chat completions endpoint.
+const completionsPath = "/chat/completions"
+
+// ChatCompletions sends a request to the the Copilot API to get completions,
+// and returns the response body.
+//
+// The request is traced with a span that is a child of the span in ctx. If the
+// client is configured with WithModelValidation, the request is checked with
+// CheckCompletionsRequest first.
+func (c *Client) ChatCompletions(ctx context.Context, r CompletionsRequest) (_ io.ReadCloser, err error) {
+	ctx, span := tracerFromContext(ctx).Start(ctx, "copilot.chat_completions",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(AttrModel, string(r.Model)),
+			attribute.Bool(AttrStream, r.Stream),
+		))
+	defer func() { endSpan(span, err) }()
+
+	if c.validateModels {
+		if err := c.CheckCompletionsRequest(ctx, r); err != nil {
+			return nil, err
+		}
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
 
-	res, err := http.DefaultClient.Do(req)
+	req, err := c.newRequest(ctx, http.MethodPost, completionsPath, r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
 	}
 
 	return res.Body, nil
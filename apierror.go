@@ -0,0 +1,214 @@
+package copilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBodySize is the maximum number of bytes read from the body of an error
+// response.
+const maxErrorBodySize = 64 << 10
+
+// APIError is returned by a Client when the Copilot API responds with a status
+// other than 200 OK. Use errors.As to get it from the returned error:
+//
+//	var apiErr *copilot.APIError
+//	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+//		sse.WriteError(w, apiErr.CopilotError())
+//	}
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Code is the error code from the response body, if any.
+	Code string
+	// Message is the error message from the response body, or the body itself if
+	// it isn't a JSON error.
+	Message string
+	// RequestID is the ID of the request, from the x-request-id or
+	// x-github-request-id response header, for reporting to GitHub.
+	RequestID string
+	// RetryAfter is how long to wait before retrying, from the Retry-After
+	// response header, or zero if it isn't set.
+	RetryAfter time.Duration
+	// RateLimit is the rate limit from the x-ratelimit-* response headers.
+	RateLimit RateLimit
+}
+
+// RateLimit is the state of a rate limit reported by the Copilot API.
+// The fields are zero if the headers aren't set.
+type RateLimit struct {
+	// Limit is the maximum number of requests in the window.
+	Limit int
+	// Remaining is the number of requests remaining in the window.
+	Remaining int
+	// Reset is the time at which the window resets.
+	Reset time.Time
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "unexpected status code: %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " (%s)", e.Code)
+	}
+	if e.Message != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " [request id %s]", e.RequestID)
+	}
+	return b.String()
+}
+
+// RateLimited reports whether the request was rejected because of a rate limit.
+func (e *APIError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// CopilotError returns an agent Error describing e for the user, for example to
+// be written with sse.WriteError. The message of a rate limit error tells the
+// user when to retry.
+func (e *APIError) CopilotError() *Error {
+	ce := &Error{
+		Type:       ErrorTypeAgent,
+		Code:       e.Code,
+		Identifier: e.RequestID,
+	}
+
+	switch {
+	case e.RateLimited():
+		if ce.Code == "" {
+			ce.Code = "rate_limited"
+		}
+		ce.Message = "Rate limited by the Copilot API"
+		if wait := e.retryIn(); wait > 0 {
+			ce.Message += ", retry in " + wait.String()
+		}
+		ce.Message += "."
+	case e.StatusCode >= http.StatusInternalServerError:
+		if ce.Code == "" {
+			ce.Code = "upstream_error"
+		}
+		ce.Message = "The Copilot API is unavailable, please try again later."
+	default:
+		if ce.Code == "" {
+			ce.Code = "request_failed"
+		}
+		ce.Message = "The request to the Copilot API failed"
+		if e.Message != "" {
+			ce.Message += ": " + e.Message
+		}
+	}
+
+	return ce
+}
+
+// retryIn returns how long to wait before retrying, rounded up to a second,
+// from RetryAfter or the reset time of the rate limit.
+func (e *APIError) retryIn() time.Duration {
+	wait := e.RetryAfter
+	if wait <= 0 && !e.RateLimit.Reset.IsZero() {
+		wait = time.Until(e.RateLimit.Reset)
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return (wait + time.Second - 1).Truncate(time.Second)
+}
+
+// newAPIError returns an APIError for the error response res, reading (up to
+// maxErrorBodySize of) its body.
+func newAPIError(res *http.Response) *APIError {
+	e := &APIError{
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get("X-Request-Id"),
+		RetryAfter: parseRetryAfter(res.Header),
+		RateLimit:  parseRateLimit(res.Header),
+	}
+	if e.RequestID == "" {
+		e.RequestID = res.Header.Get("X-GitHub-Request-Id")
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+
+	// the body is either {"error": {"code": ..., "message": ...}},
+	// {"code": ..., "message": ...}, or plain text
+	var v struct {
+		Error   json.RawMessage `json:"error"`
+		Code    any             `json:"code"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if len(v.Error) > 0 && v.Error[0] == '{' {
+			_ = json.Unmarshal(v.Error, &v)
+		} else if len(v.Error) > 0 && v.Message == "" {
+			_ = json.Unmarshal(v.Error, &v.Message)
+		}
+		if v.Code != nil {
+			e.Code = fmt.Sprint(v.Code)
+		}
+		e.Message = v.Message
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	return e
+}
+
+// parseRetryAfter parses the Retry-After header, in seconds or as an HTTP date,
+// or the retry-after-ms header.
+func parseRetryAfter(h http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(h.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.ParseFloat(v, 64); err == nil {
+		if s <= 0 {
+			return 0
+		}
+		return time.Duration(s * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// parseRateLimit parses the x-ratelimit-limit, -remaining and -reset headers
+// (reset in Unix seconds), falling back to their -requests variants (reset as a
+// duration, such as "1m30s").
+func parseRateLimit(h http.Header) RateLimit {
+	var rl RateLimit
+	get := func(name string) string {
+		if v := h.Get(name); v != "" {
+			return v
+		}
+		return h.Get(name + "-Requests")
+	}
+
+	rl.Limit, _ = strconv.Atoi(get("X-Ratelimit-Limit"))
+	rl.Remaining, _ = strconv.Atoi(get("X-Ratelimit-Remaining"))
+
+	if v := h.Get("X-Ratelimit-Reset"); v != "" {
+		if s, err := strconv.ParseInt(v, 10, 64); err == nil {
+			rl.Reset = time.Unix(s, 0)
+		}
+	} else if v := h.Get("X-Ratelimit-Reset-Requests"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			rl.Reset = time.Now().Add(d)
+		}
+	}
+
+	return rl
+}
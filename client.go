@@ -0,0 +1,268 @@
+package copilot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultBaseURL is the base URL of the Copilot API.
+const DefaultBaseURL = "https://api.githubcopilot.com"
+
+// ErrNoToken is returned by a Client when its TokenSource doesn't provide a token.
+var ErrNoToken = errors.New("no token for the Copilot API")
+
+// TokenSource provides the token used to authenticate requests to the Copilot API.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is an adapter to allow the use of ordinary functions as a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx).
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken returns a TokenSource that always returns token.
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// ContextToken is a TokenSource that returns the GitHub token from the context
+// (see GetGetHubToken). The handlers returned by NewAgentHandler and SkillsetHandler
+// add the token of the request to the context passed to the agent or skill.
+var ContextToken TokenSource = TokenSourceFunc(func(ctx context.Context) (string, error) {
+	if token := GetGetHubToken(ctx); token != "" {
+		return token, nil
+	}
+	return "", ErrNoToken
+})
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithBaseURL sets the base URL of the Copilot API, for example to point the
+// client at a proxy or a local stand-in. It defaults to DefaultBaseURL.
+func WithBaseURL(u string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(u, "/")
+	}
+}
+
+// WithHTTPClient sets the http.Client used to send requests.
+// It defaults to http.DefaultClient.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithIntegrationID sets the Copilot-Integration-Id header sent with every request.
+func WithIntegrationID(id string) ClientOption {
+	return WithHeader(CopilotIntegrationIDHeader, id)
+}
+
+// WithHeader sets an extra header sent with every request.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithTokenSource sets the TokenSource that provides the token for each request.
+// It defaults to ContextToken.
+func WithTokenSource(ts TokenSource) ClientOption {
+	return func(c *Client) {
+		c.tokens = ts
+	}
+}
+
+// WithConfig configures the client from the app's Config. It sets the User-Agent
+// header to GitHubAppUserAgent, if set.
+func WithConfig(cfg *Config) ClientOption {
+	return func(c *Client) {
+		if cfg.GitHubAppUserAgent != "" {
+			c.userAgent = cfg.GitHubAppUserAgent
+		}
+	}
+}
+
+// Client is a client for the Copilot API.
+//
+// Requests are traced with spans that are children of the span in the context,
+// and the responses are recorded to the Metrics in the context (see GetMetrics).
+// Requests that fail with a transient error are retried (see RetryPolicy).
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	header     http.Header
+	tokens     TokenSource
+
+	embeddingsBatchSize  int
+	embeddingsBatchBytes int
+
+	models         modelsCache
+	validateModels bool
+
+	retry RetryPolicy
+
+	structuredRepairs int
+}
+
+// NewClient returns a new Client for the Copilot API.
+//
+// By default, the client authenticates with the GitHub token in the context of
+// each request (see ContextToken), so within an agent it can be shared across
+// requests.
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: http.DefaultClient,
+		header:     make(http.Header),
+		tokens:     ContextToken,
+
+		embeddingsBatchSize:  DefaultEmbeddingsBatchSize,
+		embeddingsBatchBytes: DefaultEmbeddingsBatchBytes,
+
+		models: modelsCache{ttl: DefaultModelsTTL},
+		retry:  DefaultRetryPolicy,
+
+		structuredRepairs: DefaultStructuredOutputRepairs,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// newRequest returns a new request to the given path of the Copilot API, with
+// the authentication and configured headers set. If body isn't nil, it's sent
+// as JSON.
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return req, nil
+}
+
+// do sends the request and returns the response if its status is 200 OK.
+// Otherwise, it reads and closes the response body and returns the response
+// with an *APIError.
+//
+// Failed attempts are retried according to the client's RetryPolicy, as long as
+// the context of the request allows for the wait before the next attempt.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		res, err := c.send(req)
+		if err == nil || c.retry == nil {
+			return res, err
+		}
+
+		wait, ok := c.retry.Retry(attempt, err)
+		if !ok {
+			return res, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return res, err
+		}
+
+		GetLogger(ctx).DebugContext(ctx, "retrying request to the Copilot API",
+			"path", req.URL.Path, "attempt", attempt, "wait", wait, "error", err)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("error", err.Error()),
+		))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, err
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends the request once, as described by do. The status code of every
+// attempt is set on the request's span and, for the chat completions endpoint,
+// reported to Metrics.UpstreamCompletion.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	ctx := req.Context()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int(AttrHTTPStatusCode, res.StatusCode))
+	if strings.HasSuffix(req.URL.Path, completionsPath) {
+		GetMetrics(ctx).UpstreamCompletion(res.StatusCode)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return res, newAPIError(res)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,127 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingServer returns a server that responds 503 Service Unavailable with
+// the Retry-After header for the first failures requests, and then with the
+// completions stream body. It counts the requests in attempts.
+func failingServer(t *testing.T, failures int32, retryAfter string, attempts *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= failures {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestClient(url string, p RetryPolicy) *Client {
+	return NewClient(WithBaseURL(url), WithTokenSource(StaticToken("token")), WithRetryPolicy(p))
+}
+
+func TestClientRetriesWithRetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	srv := failingServer(t, 2, "1", &attempts)
+	c := newTestClient(srv.URL, &ExponentialBackoff{MaxAttempts: 3, MaxDelay: 5 * time.Second})
+
+	start := time.Now()
+	body, err := c.ChatCompletionsStream(context.Background(), CompletionsRequest{Model: CopilotModelGPT4o})
+	if err != nil {
+		t.Fatalf("ChatCompletionsStream() error = %v", err)
+	}
+	defer body.Close()
+
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("elapsed = %v, want at least the 2s of Retry-After", elapsed)
+	}
+}
+
+func TestClientStopsRetryingAfterMaxAttempts(t *testing.T) {
+	var attempts atomic.Int32
+	srv := failingServer(t, 10, "0", &attempts)
+	c := newTestClient(srv.URL, &ExponentialBackoff{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	_, err := c.ChatCompletionsStream(context.Background(), CompletionsRequest{Model: CopilotModelGPT4o})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("ChatCompletionsStream() error = %v, want a 503 *APIError", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestClientDoesntWaitPastDeadline(t *testing.T) {
+	var attempts atomic.Int32
+	srv := failingServer(t, 10, "10", &attempts)
+	c := newTestClient(srv.URL, &ExponentialBackoff{MaxAttempts: 3, MaxDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.ChatCompletionsStream(ctx, CompletionsRequest{Model: CopilotModelGPT4o})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("ChatCompletionsStream() error = %v, want a 503 *APIError", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("elapsed = %v, want no wait for a Retry-After past the deadline", elapsed)
+	}
+}
+
+func TestClientDoesntRetryStreamedResponse(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		// break the connection in the middle of the stream
+		panic(http.ErrAbortHandler)
+	}))
+	defer srv.Close()
+
+	retries := 0
+	c := newTestClient(srv.URL, RetryPolicyFunc(func(int, error) (time.Duration, bool) {
+		retries++
+		return 0, true
+	}))
+
+	body, err := c.ChatCompletionsStream(context.Background(), CompletionsRequest{Model: CopilotModelGPT4o})
+	if err != nil {
+		t.Fatalf("ChatCompletionsStream() error = %v", err)
+	}
+	defer body.Close()
+
+	if _, err := io.ReadAll(body); err == nil {
+		t.Fatal("reading the broken stream succeeded, want an error")
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	if retries != 0 {
+		t.Errorf("retry policy called %d times, want 0", retries)
+	}
+}
@@ -0,0 +1,355 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/colbylwilliams/copilot-go/internal/ssewire"
+)
+
+// MalformedChunkError is returned by CompletionsStream.Recv when a chunk in the
+// stream can't be decoded. The stream can still be read after it.
+type MalformedChunkError struct {
+	// Event is the name of the SSE event, or empty for a completions chunk.
+	Event string
+	// Data is the data of the chunk.
+	Data []byte
+	// Err is the error from decoding the data.
+	Err error
+}
+
+func (e *MalformedChunkError) Error() string {
+	if e.Event != "" {
+		return fmt.Sprintf("malformed %s event: %v", e.Event, e.Err)
+	}
+	return fmt.Sprintf("malformed completions chunk: %v", e.Err)
+}
+
+func (e *MalformedChunkError) Unwrap() error {
+	return e.Err
+}
+
+// CompletionsStream reads the chunks of a streamed completions response.
+//
+// The chunks can be read with Recv, with Next, Current and Err, or with All:
+//
+//	for stream.Next() {
+//		chunk := stream.Current()
+//		...
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+//
+// Copilot's copilot_references, copilot_confirmation and copilot_errors events
+// (sent by agents, not by the completions endpoint) are decoded into the
+// References, Confirmation and Errors fields of a Response. Other named events
+// are skipped.
+type CompletionsStream struct {
+	rc  io.ReadCloser
+	r   *ssewire.Reader
+	cur *Response
+	err error
+}
+
+// NewCompletionsStream returns a new CompletionsStream reading from rc, for
+// example the body returned by Client.ChatCompletionsStream.
+func NewCompletionsStream(rc io.ReadCloser) *CompletionsStream {
+	return &CompletionsStream{
+		rc: rc,
+		r:  ssewire.NewReader(rc),
+	}
+}
+
+// StreamCompletions sends a streaming completions request to the Copilot API
+// and returns a CompletionsStream that decodes the response, unlike
+// Client.ChatCompletionsStream, which returns the raw body.
+// The caller must close the stream.
+func (c *Client) StreamCompletions(ctx context.Context, r CompletionsRequest) (*CompletionsStream, error) {
+	rc, err := c.ChatCompletionsStream(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return NewCompletionsStream(rc), nil
+}
+
+// Recv returns the next chunk in the stream. It returns io.EOF at the end of the
+// stream, which is either the "[DONE]" message or the end of the body.
+//
+// If a chunk can't be decoded, Recv returns a *MalformedChunkError and the next
+// call to Recv continues with the following chunk. Any other error is final.
+func (s *CompletionsStream) Recv() (*Response, error) {
+	for {
+		ev, err := s.r.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var res Response
+		var v any
+
+		switch ev.Name {
+		case "", "message":
+			if strings.TrimSpace(string(ev.Data)) == "[DONE]" {
+				return nil, io.EOF
+			}
+			v = &res
+		case EventNameReferences:
+			v = &res.References
+		case EventNameConfirmation:
+			v = &res.Confirmation
+		case EventNameErrors:
+			v = &res.Errors
+		default:
+			// unknown events are skipped
+			continue
+		}
+
+		if err := json.Unmarshal(ev.Data, v); err != nil {
+			return nil, &MalformedChunkError{Event: ev.Name, Data: ev.Data, Err: err}
+		}
+
+		return &res, nil
+	}
+}
+
+// Next advances the stream to the next chunk, which is then available through
+// Current. It returns false at the end of the stream or when an error occurs,
+// including a malformed chunk. After Next returns false, Err returns the error,
+// if any.
+func (s *CompletionsStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	res, err := s.Recv()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.err = err
+		}
+		s.cur = nil
+		return false
+	}
+
+	s.cur = res
+	return true
+}
+
+// Current returns the chunk read by the last call to Next.
+func (s *CompletionsStream) Current() *Response {
+	return s.cur
+}
+
+// Err returns the error that stopped Next, or nil at the end of the stream.
+func (s *CompletionsStream) Err() error {
+	return s.err
+}
+
+// All returns an iterator over the chunks in the stream. A malformed chunk is
+// yielded as a *MalformedChunkError, and iteration continues with the following
+// chunk; any other error is yielded last.
+func (s *CompletionsStream) All() iter.Seq2[*Response, error] {
+	return func(yield func(*Response, error) bool) {
+		for {
+			res, err := s.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				var mce *MalformedChunkError
+				if !yield(nil, err) || !errors.As(err, &mce) {
+					return
+				}
+				continue
+			}
+			if !yield(res, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Close closes the underlying response body.
+func (s *CompletionsStream) Close() error {
+	return s.rc.Close()
+}
+
+// CompletionsAccumulator builds the final assistant message from the chunks of
+// a streamed completions response. The fragments of the tool calls are merged by
+// their index into complete ToolCalls.
+//
+// Only the first choice (index 0) is accumulated.
+type CompletionsAccumulator struct {
+	// ID is the ID of the response.
+	ID string
+	// Model is the model that generated the response.
+	Model string
+
+	role         string
+	content      strings.Builder
+	finishReason string
+	toolCalls    map[int]*ToolCall
+	references   []*Reference
+	errors       []*Error
+	usage        *CompletionsUsage
+}
+
+// Add adds a chunk to the accumulator.
+func (a *CompletionsAccumulator) Add(r *Response) {
+	if r == nil {
+		return
+	}
+	if r.ID != "" {
+		a.ID = r.ID
+	}
+	if r.Model != "" {
+		a.Model = r.Model
+	}
+
+	a.references = append(a.references, r.References...)
+	a.errors = append(a.errors, r.Errors...)
+	if r.Usage != nil {
+		a.usage = r.Usage
+	}
+
+	for _, c := range r.Choices {
+		if c.Index != 0 {
+			continue
+		}
+		if c.Delta.Role != "" {
+			a.role = c.Delta.Role
+		}
+		a.content.WriteString(c.Delta.Content)
+		if c.FinishReason != "" {
+			a.finishReason = c.FinishReason
+		}
+		if fc := c.Delta.FunctionCall; fc != nil {
+			// a (legacy) function call is a single tool call
+			a.addFunctionCall(a.toolCall(0), fc)
+		}
+		for _, tc := range c.Delta.ToolCalls {
+			if tc == nil || tc.Index < 0 {
+				continue
+			}
+			call := a.toolCall(tc.Index)
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			if tc.Function != nil {
+				a.addFunctionCall(call, tc.Function)
+			}
+		}
+	}
+}
+
+// toolCall returns the tool call with the given index, adding it if needed.
+func (a *CompletionsAccumulator) toolCall(index int) *ToolCall {
+	if a.toolCalls == nil {
+		a.toolCalls = make(map[int]*ToolCall)
+	}
+	call, ok := a.toolCalls[index]
+	if !ok {
+		call = &ToolCall{
+			Type:     "function",
+			Function: &ToolFunctionCall{},
+		}
+		a.toolCalls[index] = call
+	}
+	return call
+}
+
+// addFunctionCall merges a function call fragment into the tool call.
+func (a *CompletionsAccumulator) addFunctionCall(call *ToolCall, fc *ChatChoiceDeltaFunctionCall) {
+	if fc.Name != "" {
+		call.Function.Name = fc.Name
+	}
+	call.Function.Arguments += fc.Arguments
+}
+
+// Content returns the content accumulated so far.
+func (a *CompletionsAccumulator) Content() string {
+	return a.content.String()
+}
+
+// FinishReason returns the finish reason, or an empty string if the response
+// hasn't finished.
+func (a *CompletionsAccumulator) FinishReason() string {
+	return a.finishReason
+}
+
+// HasToolCalls reports whether the response has tool calls so far. It is cheaper
+// than ToolCalls while streaming.
+func (a *CompletionsAccumulator) HasToolCalls() bool {
+	return len(a.toolCalls) > 0
+}
+
+// ToolCalls returns the tool calls accumulated so far, in the order of their
+// index.
+func (a *CompletionsAccumulator) ToolCalls() []*ToolCall {
+	if len(a.toolCalls) == 0 {
+		return nil
+	}
+	calls := make([]*ToolCall, 0, len(a.toolCalls))
+	for _, i := range slices.Sorted(maps.Keys(a.toolCalls)) {
+		calls = append(calls, a.toolCalls[i])
+	}
+	return calls
+}
+
+// Usage returns the number of tokens used by the request, or nil if the stream
+// didn't include it (yet).
+func (a *CompletionsAccumulator) Usage() *CompletionsUsage {
+	return a.usage
+}
+
+// References returns the copilot_references accumulated so far.
+func (a *CompletionsAccumulator) References() []*Reference {
+	return a.references
+}
+
+// Errors returns the copilot_errors accumulated so far.
+func (a *CompletionsAccumulator) Errors() []*Error {
+	return a.errors
+}
+
+// Message returns the assistant message accumulated so far. It can be appended
+// to the messages of the next request.
+func (a *CompletionsAccumulator) Message() *Message {
+	role := ChatRole(a.role)
+	if role == "" {
+		role = ChatRoleAssistant
+	}
+	return &Message{
+		Role:       role,
+		Content:    a.content.String(),
+		ToolCalls:  a.ToolCalls(),
+		References: a.references,
+	}
+}
+
+// Accumulate reads the rest of the stream into a CompletionsAccumulator.
+// Malformed chunks are skipped.
+func (s *CompletionsStream) Accumulate() (*CompletionsAccumulator, error) {
+	var acc CompletionsAccumulator
+	for res, err := range s.All() {
+		var mce *MalformedChunkError
+		if errors.As(err, &mce) {
+			continue
+		}
+		if err != nil {
+			return &acc, err
+		}
+		acc.Add(res)
+	}
+	return &acc, nil
+}
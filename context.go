@@ -2,6 +2,7 @@ package copilot
 
 import (
 	"context"
+	"log/slog"
 )
 
 // GetSessionInfo returns the SessionInfo object from the context.
@@ -26,10 +27,29 @@ func AddGetHubToken(ctx context.Context, data string) context.Context {
 	return context.WithValue(ctx, githubTokenCtxKey, data)
 }
 
+// GetLogger returns the slog.Logger from the context, or slog.Default() if the
+// context doesn't have one.
+//
+// The handlers returned by NewAgentHandler and SkillsetHandler add a logger with
+// request-scoped attributes (thread ID, agent login, key identifier and client
+// type) to the context passed to the agent or skill.
+func GetLogger(ctx context.Context) *slog.Logger {
+	if val, ok := ctx.Value(loggerCtxKey).(*slog.Logger); ok && val != nil {
+		return val
+	}
+	return slog.Default()
+}
+
+// AddLogger adds the slog.Logger to the context.
+func AddLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey, l)
+}
+
 var (
 	// sessionCtxKey is the context.Context key to store the session context.
 	sessionCtxKey     = &contextKey{"SessionInfo"}
 	githubTokenCtxKey = &contextKey{"GithubToken"}
+	loggerCtxKey      = &contextKey{"Logger"}
 )
 
 // contextKey is a value for use with context.WithValue. It's used as
@@ -7,7 +7,11 @@
 // [agents]: https://docs.github.com/copilot/building-copilot-extensions/building-a-copilot-agent-for-your-copilot-extension/about-copilot-agents
 package copilot
 
-import "github.com/colbylwilliams/copilot-go/jsonschema"
+import (
+	"encoding/json"
+
+	"github.com/colbylwilliams/copilot-go/jsonschema"
+)
 
 type ChatRole string
 
@@ -31,6 +35,45 @@ type Request struct {
 	FrequencyPenalty float32    `json:"frequency_penalty"`
 	Skills           []string   `json:"copilot_skills"`
 	Agent            string     `json:"agent"`
+
+	// present are the JSON keys of the sampling parameters the request was
+	// decoded with.
+	present map[string]bool
+}
+
+// samplingParams are the JSON keys of the sampling parameters of a Request.
+var samplingParams = []string{"temperature", "top_p", "max_tokens", "presence_penalty", "frequency_penalty"}
+
+// UnmarshalJSON unmarshals a Request from JSON, recording which sampling
+// parameters it has (with a non-null value), so NewCompletionsRequest can tell
+// a parameter set to zero from a missing one.
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type Alias Request
+	if err := json.Unmarshal(b, (*Alias)(r)); err != nil {
+		return err
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	r.present = nil
+	for _, key := range samplingParams {
+		if v, ok := m[key]; ok && string(v) != "null" {
+			if r.present == nil {
+				r.present = make(map[string]bool)
+			}
+			r.present[key] = true
+		}
+	}
+	return nil
+}
+
+// has reports whether the sampling parameter with the JSON key is set: non-zero,
+// or present in the JSON the request was decoded from, so an explicit zero is
+// kept and a value set after decoding isn't lost.
+func (r *Request) has(key string, zero bool) bool {
+	return r.present[key] || !zero
 }
 
 // Message is a message in the request.
@@ -74,18 +117,32 @@ type Response struct {
 	References        []*Reference  `json:"copilot_references,omitempty"`
 	Confirmation      *Confirmation `json:"copilot_confirmation,omitempty"`
 	Errors            []*Error      `json:"copilot_errors,omitempty"`
+
+	// Usage is the number of tokens used by the request. Upstream models send it
+	// in the last chunk of the stream, which may have no choices.
+	Usage *CompletionsUsage `json:"usage,omitempty"`
+	// PromptFilterResults are the content filter results of the prompt, if the
+	// upstream model filters content.
+	PromptFilterResults []*PromptFilterResult `json:"prompt_filter_results,omitempty"`
 }
 
 const (
 	ChatFinishReasonStop         string = "stop"
 	ChatFinishReasonToolCalls    string = "tool_calls"
 	ChatFinishReasonFunctionCall string = "function_call"
+	ChatFinishReasonLength       string = "length"
+
+	// ChatFinishReasonContentFilter is the finish reason of a response that was
+	// stopped by a content filter (see ContentFilterResults).
+	ChatFinishReasonContentFilter string = "content_filter"
 )
 
 type ChatChoice struct {
-	Index        int64           `json:"index"`
-	FinishReason string          `json:"finish_reason,omitempty"`
-	Delta        ChatChoiceDelta `json:"delta"`
+	Index                int64                 `json:"index"`
+	FinishReason         string                `json:"finish_reason,omitempty"`
+	Delta                ChatChoiceDelta       `json:"delta"`
+	Logprobs             *Logprobs             `json:"logprobs,omitempty"`
+	ContentFilterResults *ContentFilterResults `json:"content_filter_results,omitempty"`
 }
 
 type ChatChoiceDelta struct {
@@ -93,9 +150,78 @@ type ChatChoiceDelta struct {
 	Role         string                       `json:"role,omitempty"`
 	Name         string                       `json:"name,omitempty"`
 	FunctionCall *ChatChoiceDeltaFunctionCall `json:"function_call,omitempty"`
+	ToolCalls    []*ChatChoiceDeltaToolCall   `json:"tool_calls,omitempty"`
 }
 
 type ChatChoiceDeltaFunctionCall struct {
 	Name      string `json:"name"`
 	Arguments string `json:"arguments"`
 }
+
+// ChatChoiceDeltaToolCall is a fragment of a tool call in a streamed response.
+// The first fragment of a tool call has its ID, type and function name; the
+// following fragments with the same Index add to its arguments.
+// CompletionsAccumulator merges the fragments into complete ToolCalls.
+type ChatChoiceDeltaToolCall struct {
+	Index    int                          `json:"index"`
+	ID       string                       `json:"id,omitempty"`
+	Type     string                       `json:"type,omitempty"`
+	Function *ChatChoiceDeltaFunctionCall `json:"function,omitempty"`
+}
+
+// Logprobs are the log probabilities of the tokens of a choice.
+type Logprobs struct {
+	Content []*TokenLogprob `json:"content"`
+}
+
+// TokenLogprob is the log probability of a token, and of the most likely tokens
+// at its position (TopLogprobs).
+type TokenLogprob struct {
+	Token       string          `json:"token"`
+	Logprob     float64         `json:"logprob"`
+	Bytes       []int           `json:"bytes,omitempty"`
+	TopLogprobs []*TokenLogprob `json:"top_logprobs,omitempty"`
+}
+
+// ContentFilterResults are the results of the content filters of an upstream
+// model, for a choice or a prompt.
+type ContentFilterResults struct {
+	Hate                  *ContentFilterResult `json:"hate,omitempty"`
+	SelfHarm              *ContentFilterResult `json:"self_harm,omitempty"`
+	Sexual                *ContentFilterResult `json:"sexual,omitempty"`
+	Violence              *ContentFilterResult `json:"violence,omitempty"`
+	Profanity             *ContentFilterResult `json:"profanity,omitempty"`
+	Jailbreak             *ContentFilterResult `json:"jailbreak,omitempty"`
+	ProtectedMaterialText *ContentFilterResult `json:"protected_material_text,omitempty"`
+	ProtectedMaterialCode *ContentFilterResult `json:"protected_material_code,omitempty"`
+}
+
+// Filtered reports whether any of the content filters filtered the content.
+func (r *ContentFilterResults) Filtered() bool {
+	for _, f := range []*ContentFilterResult{
+		r.Hate, r.SelfHarm, r.Sexual, r.Violence, r.Profanity,
+		r.Jailbreak, r.ProtectedMaterialText, r.ProtectedMaterialCode,
+	} {
+		if f != nil && f.Filtered {
+			return true
+		}
+	}
+	return false
+}
+
+// ContentFilterResult is the result of a content filter.
+type ContentFilterResult struct {
+	Filtered bool `json:"filtered"`
+	// Severity is the severity of the content (safe, low, medium or high), for
+	// the filters that measure it.
+	Severity string `json:"severity,omitempty"`
+	// Detected is whether the content was detected, for the filters that
+	// detect it (jailbreak and protected material).
+	Detected *bool `json:"detected,omitempty"`
+}
+
+// PromptFilterResult is the result of the content filters for a prompt.
+type PromptFilterResult struct {
+	PromptIndex          int                   `json:"prompt_index"`
+	ContentFilterResults *ContentFilterResults `json:"content_filter_results,omitempty"`
+}
@@ -0,0 +1,177 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultEmbeddingsBatchSize is the maximum number of inputs sent in one
+	// embeddings request, unless configured with WithEmbeddingsBatchLimits.
+	DefaultEmbeddingsBatchSize = 512
+
+	// DefaultEmbeddingsBatchBytes is the maximum total size, in bytes, of the
+	// inputs sent in one embeddings request, unless configured with
+	// WithEmbeddingsBatchLimits. A single larger input is sent on its own.
+	DefaultEmbeddingsBatchBytes = 256 * 1024
+)
+
+// WithEmbeddingsBatchLimits sets the maximum number of inputs and the maximum
+// total size of the inputs (in bytes) sent in one embeddings request. A value of
+// zero or less keeps the default.
+func WithEmbeddingsBatchLimits(inputs, bytes int) ClientOption {
+	return func(c *Client) {
+		if inputs > 0 {
+			c.embeddingsBatchSize = inputs
+		}
+		if bytes > 0 {
+			c.embeddingsBatchBytes = bytes
+		}
+	}
+}
+
+// EmbeddingsRequest is a request to the Copilot API to get embeddings.
+type EmbeddingsRequest struct {
+	Model CopilotModel `json:"model" default:"text-embedding-ada-002"`
+	Input []string     `json:"input"`
+}
+
+// EmbeddingsResponse is a response from the Copilot API to get embeddings.
+type EmbeddingsResponse struct {
+	Object string           `json:"object,omitempty" default:"list"`
+	Model  string           `json:"model"`
+	Data   []Embedding      `json:"data"`
+	Usage  *EmbeddingsUsage `json:"usage,omitempty"`
+}
+
+// Embedding is the embedding of one input.
+type Embedding struct {
+	Object    string    `json:"object,omitempty" default:"embedding"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// EmbeddingsUsage is the number of tokens used by an embeddings request.
+type EmbeddingsUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// Vectors returns the embeddings of the response, in the order of the inputs.
+func (r *EmbeddingsResponse) Vectors() [][]float64 {
+	v := make([][]float64, len(r.Data))
+	for i, e := range r.Data {
+		v[i] = e.Embedding
+	}
+	return v
+}
+
+// Embeddings sends requests to the Copilot API to get the embeddings of the
+// inputs of r. The model defaults to CopilotModelEmbeddings.
+//
+// The inputs are split into batches that respect the client's batch limits (see
+// WithEmbeddingsBatchLimits), which are sent one after another. The returned
+// response has one Embedding per input, in the order of the inputs (Index is the
+// index of the input in r.Input), and the usage of all the batches.
+func (c *Client) Embeddings(ctx context.Context, r EmbeddingsRequest) (_ *EmbeddingsResponse, err error) {
+	if len(r.Input) == 0 {
+		return nil, errors.New("embeddings request has no input")
+	}
+	if r.Model == "" {
+		r.Model = CopilotModelEmbeddings
+	}
+
+	ctx, span := tracerFromContext(ctx).Start(ctx, "copilot.embeddings",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(AttrModel, string(r.Model)),
+			attribute.Int(AttrEmbeddingsInputs, len(r.Input)),
+		))
+	defer func() { endSpan(span, err) }()
+
+	res := &EmbeddingsResponse{
+		Object: "list",
+		Model:  string(r.Model),
+		Data:   make([]Embedding, 0, len(r.Input)),
+		Usage:  &EmbeddingsUsage{},
+	}
+
+	for start := 0; start < len(r.Input); {
+		end := c.embeddingsBatchEnd(r.Input, start)
+
+		batch, err := c.embeddings(ctx, EmbeddingsRequest{Model: r.Model, Input: r.Input[start:end]})
+		if err != nil {
+			return nil, fmt.Errorf("embeddings batch %d-%d: %w", start, end-1, err)
+		}
+
+		// the data of a batch isn't guaranteed to be in the order of its inputs
+		data := make([]Embedding, end-start)
+		for _, e := range batch.Data {
+			if e.Index < 0 || e.Index >= len(data) || data[e.Index].Embedding != nil {
+				return nil, fmt.Errorf("embeddings batch %d-%d: unexpected index %d", start, end-1, e.Index)
+			}
+			data[e.Index] = e
+		}
+		for i := range data {
+			if data[i].Embedding == nil {
+				return nil, fmt.Errorf("embeddings batch %d-%d: missing embedding for input %d", start, end-1, start+i)
+			}
+			data[i].Object = "embedding"
+			data[i].Index = start + i
+		}
+		res.Data = append(res.Data, data...)
+
+		if batch.Model != "" {
+			res.Model = batch.Model
+		}
+		if batch.Usage != nil {
+			res.Usage.PromptTokens += batch.Usage.PromptTokens
+			res.Usage.TotalTokens += batch.Usage.TotalTokens
+		}
+
+		start = end
+	}
+
+	return res, nil
+}
+
+// embeddingsBatchEnd returns the end (exclusive) of the batch of inputs that
+// starts at start. A batch has at least one input.
+func (c *Client) embeddingsBatchEnd(inputs []string, start int) int {
+	end, size := start, 0
+	for end < len(inputs) && end-start < c.embeddingsBatchSize {
+		if end > start && size+len(inputs[end]) > c.embeddingsBatchBytes {
+			break
+		}
+		size += len(inputs[end])
+		end++
+	}
+	return end
+}
+
+// embeddings sends a single embeddings request.
+func (c *Client) embeddings(ctx context.Context, r EmbeddingsRequest) (*EmbeddingsResponse, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, "/embeddings", r)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var er EmbeddingsResponse
+	if err := json.NewDecoder(res.Body).Decode(&er); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings response: %w", err)
+	}
+
+	return &er, nil
+}
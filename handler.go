@@ -0,0 +1,344 @@
+package copilot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultMaxBodySize is the maximum size of a request body, unless configured
+// with WithMaxBodySize.
+const DefaultMaxBodySize int64 = 10 << 20 // 10 MB
+
+// HandlerOption configures the handlers returned by NewAgentHandler,
+// SkillsetHandler and NewSkillset.
+type HandlerOption func(*handlerOptions)
+
+// ErrorFunc is called with every error that occurs while handling a request,
+// including the errors returned by an agent or skill.
+type ErrorFunc func(r *http.Request, err error)
+
+// RejectFunc writes the response for a request that fails before (or while)
+// the agent or skill is executed. status is the suggested HTTP status code.
+type RejectFunc func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+type handlerOptions struct {
+	logger      *slog.Logger
+	onError     ErrorFunc
+	reject      RejectFunc
+	maxBodySize int64
+	readTimeout time.Duration
+	tracer      trace.Tracer
+	metrics     Metrics
+}
+
+// WithErrorHandler sets a callback that is called with every error that occurs
+// while handling a request.
+func WithErrorHandler(fn ErrorFunc) HandlerOption {
+	return func(o *handlerOptions) {
+		o.onError = fn
+	}
+}
+
+// WithLogger sets the logger the handler logs to. The handler adds a logger
+// derived from it, with request-scoped attributes, to the context passed to the
+// agent or skill (see GetLogger). The GitHub token and message content are
+// never logged.
+// It defaults to slog.Default().
+func WithLogger(l *slog.Logger) HandlerOption {
+	return func(o *handlerOptions) {
+		o.logger = l
+	}
+}
+
+// WithMaxBodySize sets the maximum size of a request body in bytes. Requests
+// with a larger body are rejected with 413 Request Entity Too Large.
+// It defaults to DefaultMaxBodySize.
+func WithMaxBodySize(n int64) HandlerOption {
+	return func(o *handlerOptions) {
+		o.maxBodySize = n
+	}
+}
+
+// WithReadTimeout sets the maximum duration for reading the request body.
+// It is applied in addition to any timeout configured on the http.Server.
+func WithReadTimeout(d time.Duration) HandlerOption {
+	return func(o *handlerOptions) {
+		o.readTimeout = d
+	}
+}
+
+// WithRejectHandler sets the function that writes the response for rejected
+// requests. By default, a JSON body with an error message is written:
+//
+//	{"error": "missing required header X-Github-Token"}
+func WithRejectHandler(fn RejectFunc) HandlerOption {
+	return func(o *handlerOptions) {
+		o.reject = fn
+	}
+}
+
+func newHandlerOptions(opts []HandlerOption) *handlerOptions {
+	o := &handlerOptions{
+		logger:      slog.Default(),
+		reject:      writeRejection,
+		maxBodySize: DefaultMaxBodySize,
+		tracer:      otel.GetTracerProvider().Tracer(tracerName),
+		metrics:     noopMetrics{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// fail logs err, calls the error callback, and rejects the request with the
+// given status, unless a response was already started.
+//
+// Client errors (statuses below 500), such as a missing header or an invalid
+// signature, are logged at the warn level, and server errors, including the
+// errors returned by an agent or skill, at the error level.
+func (o *handlerOptions) fail(w http.ResponseWriter, r *http.Request, status int, err error) {
+	level := slog.LevelError
+	if status < http.StatusInternalServerError {
+		level = slog.LevelWarn
+	}
+	GetLogger(r.Context()).Log(r.Context(), level, "failed to handle request", "status", status, "error", err)
+
+	if o.onError != nil {
+		o.onError(r, err)
+	}
+
+	if rw, ok := w.(*responseWriter); ok && rw.wroteHeader {
+		// the response (probably a stream) is already underway,
+		// so we can't change the status code anymore
+		return
+	}
+
+	o.reject(w, r, status, err)
+}
+
+// withLogger returns a shallow copy of r with a logger derived from the handler's
+// logger in its context. The logger has the request-scoped attributes known
+// before the body is read.
+func (o *handlerOptions) withLogger(r *http.Request) *http.Request {
+	log := o.logger
+	if keyID := r.Header.Get(PublicKeyIdentifierHeader); keyID != "" {
+		log = log.With(slog.String("key_id", keyID))
+	}
+	if client := r.Header.Get(CopilotIntegrationIDHeader); client != "" {
+		log = log.With(slog.String("client", client))
+	}
+	return r.WithContext(AddLogger(r.Context(), log))
+}
+
+// withLogAttrs returns a shallow copy of r with the attributes added to the
+// logger in its context.
+func withLogAttrs(r *http.Request, attrs ...any) *http.Request {
+	ctx := r.Context()
+	return r.WithContext(AddLogger(ctx, GetLogger(ctx).With(attrs...)))
+}
+
+// verifyError is returned by readVerifiedBody with the status to reject the
+// request with and the reason to report to Metrics.SignatureFailure.
+type verifyError struct {
+	status int
+	reason string
+	err    error
+}
+
+func (e *verifyError) Error() string { return e.err.Error() }
+func (e *verifyError) Unwrap() error { return e.err }
+
+// readVerifiedBody reads the request body and verifies its signature with v.
+// If the headers are missing, the body can't be read, or the signature is
+// invalid, it returns a *verifyError.
+func (o *handlerOptions) readVerifiedBody(v PayloadVerifier, w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	keyID, err := getRequiredHeader(r, PublicKeyIdentifierHeader)
+	if err != nil {
+		return nil, &verifyError{http.StatusBadRequest, SignatureFailureMissingHeader, err}
+	}
+	signature, err := getRequiredHeader(r, PublicKeySignatureHeader)
+	if err != nil {
+		return nil, &verifyError{http.StatusBadRequest, SignatureFailureMissingHeader, err}
+	}
+
+	if o.readTimeout > 0 {
+		// not all ResponseWriters support deadlines, in which case
+		// we rely on the timeouts configured on the server
+		_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(o.readTimeout))
+	}
+
+	body := r.Body
+	if o.maxBodySize > 0 {
+		body = http.MaxBytesReader(w, r.Body, o.maxBodySize)
+	}
+	defer body.Close()
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			return nil, &verifyError{http.StatusRequestEntityTooLarge, SignatureFailureBodyTooLarge, fmt.Errorf("request body too large: %w", err)}
+		}
+		return nil, &verifyError{http.StatusBadRequest, SignatureFailureReadBody, fmt.Errorf("failed to read request body: %w", err)}
+	}
+
+	var isValid bool
+	if kv, ok := v.(PayloadKeyVerifier); ok {
+		isValid, err = kv.VerifyKey(b, signature, keyID)
+	} else {
+		isValid, err = v.Verify(b, signature)
+	}
+	if err != nil {
+		reason := SignatureFailureMalformed
+		switch {
+		case errors.Is(err, ErrUnknownPublicKey):
+			reason = SignatureFailureUnknownKey
+		case errors.Is(err, ErrPublicKeyFetch):
+			reason = SignatureFailureKeyFetch
+		}
+		return nil, &verifyError{http.StatusUnauthorized, reason, fmt.Errorf("failed to validate payload signature: %w", err)}
+	}
+	if !isValid {
+		return nil, &verifyError{http.StatusUnauthorized, SignatureFailureInvalid, errors.New("invalid payload signature")}
+	}
+
+	return b, nil
+}
+
+// verify starts a span and calls readVerifiedBody. If the payload can't be
+// verified, it rejects the request and returns false.
+func (o *handlerOptions) verify(v PayloadVerifier, w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	_, span := o.tracer.Start(r.Context(), "copilot.verify")
+	if keyID := r.Header.Get(PublicKeyIdentifierHeader); keyID != "" {
+		span.SetAttributes(attribute.String(AttrKeyID, keyID))
+	}
+
+	b, err := o.readVerifiedBody(v, w, r)
+	endSpan(span, err)
+	if err != nil {
+		var ve *verifyError
+		if errors.As(err, &ve) {
+			o.metrics.SignatureFailure(ve.reason)
+			o.fail(w, r, ve.status, ve.err)
+		} else {
+			o.fail(w, r, http.StatusInternalServerError, err)
+		}
+		return nil, false
+	}
+
+	return b, true
+}
+
+func getRequiredHeader(r *http.Request, key string) (string, error) {
+	value := r.Header.Get(key)
+	if value == "" {
+		return "", fmt.Errorf("missing required header %s", key)
+	}
+	return value, nil
+}
+
+// writeRejection is the default RejectFunc. It writes a JSON body with the
+// error message, or only the status text for server errors.
+func writeRejection(w http.ResponseWriter, _ *http.Request, status int, err error) {
+	msg := http.StatusText(status)
+	if status < http.StatusInternalServerError && err != nil {
+		msg = err.Error()
+	}
+	_ = writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
+
+// responseWriter wraps the http.ResponseWriter passed to agents and skills to
+// record whether the response was started, when the first byte and the first
+// delta were written, and the number of deltas written.
+type responseWriter struct {
+	http.ResponseWriter
+	metrics     Metrics
+	wroteHeader bool
+	firstByte   time.Time
+	firstDelta  time.Time
+	deltas      int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if w.firstByte.IsZero() && len(b) > 0 {
+		w.firstByte = time.Now()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, so streamed responses are sent to the client
+// as they are written.
+func (w *responseWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// RecordStreamEvent implements StreamEventRecorder.
+func (w *responseWriter) RecordStreamEvent(e StreamEvent) {
+	if e == StreamEventDelta {
+		if w.deltas == 0 {
+			w.firstDelta = time.Now()
+		}
+		w.deltas++
+	}
+	w.metrics.StreamEvent(e)
+	if r, ok := w.ResponseWriter.(StreamEventRecorder); ok {
+		r.RecordStreamEvent(e)
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recordStreamMetrics records the stream duration and the time to first delta
+// (relative to start).
+func (w *responseWriter) recordStreamMetrics(start time.Time) {
+	w.metrics.StreamDuration(time.Since(start))
+	if !w.firstDelta.IsZero() {
+		w.metrics.TimeToFirstDelta(w.firstDelta.Sub(start))
+	}
+}
+
+// setStreamAttributes sets the time to first byte (relative to start) and the
+// number of deltas written on the span.
+func (w *responseWriter) setStreamAttributes(span trace.Span, start time.Time) {
+	span.SetAttributes(attribute.Int(AttrStreamDeltas, w.deltas))
+	if !w.firstByte.IsZero() {
+		span.SetAttributes(attribute.Int64(AttrStreamFirstByteMilli, w.firstByte.Sub(start).Milliseconds()))
+	}
+}
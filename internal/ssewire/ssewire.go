@@ -0,0 +1,151 @@
+// Package ssewire reads Server-Sent Events (SSE) from a stream, following the
+// event stream interpretation rules of the HTML specification.
+//
+// It is used by the copilot and sse packages to decode Copilot-format event
+// streams.
+package ssewire
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// MaxLineSize is the maximum size of a single line in an event stream.
+const MaxLineSize = 16 << 20 // 16 MB
+
+// Event is a dispatched SSE event.
+type Event struct {
+	// Name is the event name set with an "event:" field, or empty for the
+	// default ("message") event.
+	Name string
+	// Data is the value of the "data:" fields, joined with newlines.
+	Data []byte
+	// ID is the last event ID set with an "id:" field.
+	ID string
+}
+
+// Reader reads events from an event stream.
+type Reader struct {
+	s *bufio.Scanner
+
+	// Retry is the reconnection time (in milliseconds) set by the last valid
+	// "retry:" field, or zero if the stream didn't set one.
+	Retry int
+
+	lastID string
+	// started reports whether the first line was read.
+	started bool
+}
+
+// bom is the UTF-8 byte order mark, which is stripped from the start of the
+// stream.
+var bom = []byte("\xEF\xBB\xBF")
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), MaxLineSize)
+	s.Split(scanLines)
+	return &Reader{s: s}
+}
+
+// Next returns the next event in the stream. Comments and fields with unknown
+// names are ignored. At the end of the stream, Next returns io.EOF; an event
+// that isn't terminated by a blank line is discarded.
+func (r *Reader) Next() (*Event, error) {
+	var (
+		name    string
+		data    bytes.Buffer
+		hasData bool
+	)
+
+	for r.s.Scan() {
+		line := r.s.Bytes()
+		if !r.started {
+			r.started = true
+			line = bytes.TrimPrefix(line, bom)
+		}
+
+		if len(line) == 0 {
+			// a blank line dispatches the event
+			if !hasData {
+				name = ""
+				continue
+			}
+			return &Event{Name: name, Data: bytes.Clone(data.Bytes()), ID: r.lastID}, nil
+		}
+
+		if line[0] == ':' {
+			// comment
+			continue
+		}
+
+		field, value, found := bytes.Cut(line, []byte(":"))
+		if found {
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+
+		switch string(field) {
+		case "event":
+			name = string(value)
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+		case "id":
+			if !bytes.ContainsRune(value, 0) {
+				r.lastID = string(value)
+			}
+		case "retry":
+			if n, err := strconv.Atoi(string(value)); err == nil && n >= 0 && isDigits(value) {
+				r.Retry = n
+			}
+		}
+	}
+
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+// scanLines is a bufio.SplitFunc that splits on "\r\n", "\n" or "\r".
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// data[i] == '\r'
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// need more data to know if the \r is followed by \n
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -4,7 +4,12 @@
 // It is meant to be a simple way to describe JSON Schemas for use this packages.
 package jsonschema
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
 
 // DataType is a string that specifies the data type of a JSON Schema.
 type DataType string
@@ -35,34 +40,254 @@ const (
 // Definition is a struct for describing a JSON Schema.
 type Definition struct {
 	// Type specifies the data type of the schema.
-	Type DataType `json:"type,omitempty"`
+	Type DataType `json:"-"`
+
+	// Types specifies the data types of the schema, if it allows more than one
+	// (for example a nullable string: []DataType{String, Null}). If set, it takes
+	// precedence over Type.
+	Types []DataType `json:"-"`
 
 	// Description is the description of the schema.
 	Description string `json:"description,omitempty"`
 
 	// Enum is used to restrict a value to a fixed set of values. It must be an array with at least
-	// one element, where each element is unique. You will probably only use this with strings.
-	Enum []string `json:"enum,omitempty"`
+	// one element, where each element is unique. The values can be of any type, such as strings
+	// or numbers.
+	Enum []any `json:"enum,omitempty"`
+
+	// Const restricts a value to a single value. Since nil means no const, a null
+	// const is json.RawMessage("null"), which is how it is unmarshaled.
+	Const any `json:"const,omitempty"`
+
+	// Default is the default value of the schema. As with Const, a null default
+	// is json.RawMessage("null").
+	Default any `json:"default,omitempty"`
 
 	// Properties describes the properties of an object, if the schema type is Object.
-	Properties map[string]Definition `json:"properties"`
+	Properties map[string]Definition `json:"properties,omitempty"`
 
 	// Required specifies which properties are required, if the schema type is Object.
+	// An empty (non-nil) slice is marshaled as an empty array.
 	Required []string `json:"required,omitempty"`
 
+	// AdditionalProperties describes the properties of an object that aren't listed
+	// in Properties, if the schema type is Object. It is either a bool (false
+	// disallows additional properties) or a Definition (or *Definition) that the
+	// additional properties must conform to. If nil, any additional properties
+	// are allowed.
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+
 	// Items specifies which data type an array contains, if the schema type is Array.
 	Items *Definition `json:"items,omitempty"`
+
+	// Minimum and Maximum restrict the (inclusive) range of a number.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+
+	// MinLength and MaxLength restrict the length of a string, in characters.
+	MinLength *int `json:"minLength,omitempty"`
+	MaxLength *int `json:"maxLength,omitempty"`
+
+	// Pattern is a regular expression a string must match.
+	Pattern string `json:"pattern,omitempty"`
+
+	// Format is the semantic format of a string, for example "date-time" or "email".
+	// It is an annotation and is not validated.
+	Format string `json:"format,omitempty"`
+
+	// AnyOf, OneOf and AllOf compose the schema from other schemas: a value must
+	// conform to at least one, exactly one, or all of them.
+	AnyOf []Definition `json:"anyOf,omitempty"`
+	OneOf []Definition `json:"oneOf,omitempty"`
+	AllOf []Definition `json:"allOf,omitempty"`
+
+	// Ref is a reference to another schema, for example "#/$defs/Node".
+	Ref string `json:"$ref,omitempty"`
+
+	// Defs are the schemas that can be referenced with Ref.
+	Defs map[string]Definition `json:"$defs,omitempty"`
+
+	// Extra holds the keywords of the schema that aren't modeled by Definition
+	// (for example "title" or "$schema"), so they are preserved when a schema is
+	// unmarshaled and marshaled again.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Float returns a pointer to v, for use as Minimum or Maximum.
+func Float(v float64) *float64 { return &v }
+
+// Int returns a pointer to v, for use as MinLength or MaxLength.
+func Int(v int) *int { return &v }
+
+// HasType reports whether the schema allows values of data type t, according
+// to Type or Types. It returns false if neither is set.
+func (d *Definition) HasType(t DataType) bool {
+	if len(d.Types) > 0 {
+		for _, dt := range d.Types {
+			if dt == t {
+				return true
+			}
+		}
+		return false
+	}
+	return d.Type == t
 }
 
 // MarshalJSON marshals a Definition to JSON.
+//
+// Properties is always set for a schema of type Object, because some models
+// require it.
 func (d Definition) MarshalJSON() ([]byte, error) {
-	if d.Properties == nil {
+	if d.Properties == nil && d.HasType(Object) {
 		d.Properties = make(map[string]Definition) //nolint:revive // not meant to be visible externally
 	}
+
+	var typ any
+	switch {
+	case len(d.Types) > 0:
+		typ = d.Types
+	case d.Type != "":
+		typ = d.Type
+	}
+
+	// an empty (non-nil) properties map is kept for objects only, and an
+	// empty (non-nil) required slice is kept
+	var props, required any
+	if d.Properties != nil {
+		props = d.Properties
+	}
+	if d.Required != nil {
+		required = d.Required
+	}
+
 	type Alias Definition
-	return json.Marshal(struct {
+	b, err := json.Marshal(struct {
+		Type       any `json:"type,omitempty"`
+		Properties any `json:"properties,omitempty"`
+		Required   any `json:"required,omitempty"`
 		Alias
 	}{
-		Alias: Alias(d),
+		Type:       typ,
+		Properties: props,
+		Required:   required,
+		Alias:      Alias(d),
 	})
+	if err != nil || len(d.Extra) == 0 {
+		return b, err
+	}
+
+	// merge the extra keywords, without overwriting the modeled ones
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range d.Extra {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON unmarshals a Definition from JSON. The type keyword can be a
+// single type (Type) or a list of types (Types), and AdditionalProperties is
+// unmarshaled as a bool or a *Definition. A null const or default is kept as
+// json.RawMessage("null"). Keywords that aren't modeled by Definition are kept
+// in Extra.
+func (d *Definition) UnmarshalJSON(b []byte) error {
+	type Alias Definition
+	aux := struct {
+		Type                 json.RawMessage `json:"type,omitempty"`
+		AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+		*Alias
+	}{
+		Alias: (*Alias)(d),
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	d.Type, d.Types = "", nil
+	if len(aux.Type) > 0 {
+		if aux.Type[0] == '[' {
+			if err := json.Unmarshal(aux.Type, &d.Types); err != nil {
+				return err
+			}
+		} else if err := json.Unmarshal(aux.Type, &d.Type); err != nil {
+			return err
+		}
+	}
+
+	d.AdditionalProperties = nil
+	if len(aux.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(aux.AdditionalProperties, &allowed); err == nil {
+			d.AdditionalProperties = allowed
+		} else {
+			var ap Definition
+			if err := json.Unmarshal(aux.AdditionalProperties, &ap); err != nil {
+				return err
+			}
+			d.AdditionalProperties = &ap
+		}
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	if isNull(m["const"]) {
+		d.Const = json.RawMessage("null")
+	}
+	if isNull(m["default"]) {
+		d.Default = json.RawMessage("null")
+	}
+
+	d.Extra = nil
+	for k, v := range m {
+		if !knownKeywords[k] {
+			if d.Extra == nil {
+				d.Extra = make(map[string]json.RawMessage)
+			}
+			d.Extra[k] = v
+		}
+	}
+
+	return nil
 }
+
+// isNull reports whether the raw JSON value is null.
+func isNull(v json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(v), []byte("null"))
+}
+
+// additionalProperties returns the AdditionalProperties of the schema as a bool
+// (whether additional properties are allowed) and a *Definition (that they must
+// conform to, if any).
+func (d *Definition) additionalProperties() (bool, *Definition) {
+	switch ap := d.AdditionalProperties.(type) {
+	case bool:
+		return ap, nil
+	case *bool:
+		return ap == nil || *ap, nil
+	case Definition:
+		return true, &ap
+	case *Definition:
+		return true, ap
+	default:
+		return true, nil
+	}
+}
+
+// knownKeywords are the JSON keywords modeled by Definition.
+var knownKeywords = func() map[string]bool {
+	m := map[string]bool{"type": true}
+	t := reflect.TypeFor[Definition]()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			m[name] = true
+		}
+	}
+	return m
+}()
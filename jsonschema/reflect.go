@@ -0,0 +1,444 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRecursiveType is returned when reflecting a type that refers to itself in a
+// way that can't be expressed with a reference, such as a struct that embeds
+// itself.
+var ErrRecursiveType = errors.New("jsonschema: recursive type")
+
+// Reflect returns a Definition for the type T, built from its struct fields,
+// their json tags, and their jsonschema tags. See For for details.
+func Reflect[T any]() (Definition, error) {
+	return reflectType(reflect.TypeFor[T]())
+}
+
+// MustReflect is like Reflect but panics if the type can't be reflected.
+// It simplifies the registration of tools and skills with a parameters type.
+func MustReflect[T any]() Definition {
+	d, err := Reflect[T]()
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// For returns a Definition for the type of v.
+//
+// Structs are reflected as objects. The properties are the exported fields,
+// named and skipped according to their json tags; the fields of embedded structs
+// are promoted, as with encoding/json. A property is required unless it is a
+// pointer or its json tag has the omitempty option.
+//
+// The jsonschema tag adds to the definition of a field. It is a comma separated
+// list of options (use \, for a comma in a value):
+//
+//	description=...  the description of the property
+//	enum=a|b|c       the allowed values of the property, as JSON unless it is a string
+//	required         the property is required, even if it is a pointer or omitempty
+//	format=...       the format of the property, for example email
+//	pattern=...      the regular expression the property must match
+//	minimum=n        the minimum value of the property
+//	maximum=n        the maximum value of the property
+//	minLength=n      the minimum length of the property
+//	maxLength=n      the maximum length of the property
+//	default=...      the default value of the property, as JSON or a plain string
+//
+// For example:
+//
+//	type Params struct {
+//		Query  string   `json:"query" jsonschema:"description=The search query"`
+//		State  string   `json:"state,omitempty" jsonschema:"enum=open|closed"`
+//		Limit  *int     `json:"limit"`
+//		Labels []string `json:"labels"`
+//	}
+//
+// Slices and arrays are reflected as arrays ([]byte as a string, like encoding/json),
+// maps as objects with additionalProperties, time.Time as a date-time string, and
+// interfaces as a definition that allows any value.
+//
+// A struct type that refers to itself is defined once in the $defs of the root
+// definition and referenced with $ref ("#" if it's the root type itself).
+func For(v any) (Definition, error) {
+	if v == nil {
+		return Definition{}, errors.New("jsonschema: cannot reflect nil")
+	}
+	return reflectType(reflect.TypeOf(v))
+}
+
+var (
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+	timeType       = reflect.TypeFor[time.Time]()
+)
+
+func reflectType(t reflect.Type) (Definition, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	r := &reflector{
+		root:      t,
+		visiting:  make(map[reflect.Type]bool),
+		recursive: make(map[reflect.Type]bool),
+		names:     make(map[reflect.Type]string),
+	}
+	d, err := r.reflect(t)
+	if err != nil {
+		return Definition{}, err
+	}
+	d.Defs = r.defs
+	return d, nil
+}
+
+type reflector struct {
+	// root is the type being reflected, referenced as "#".
+	root reflect.Type
+	// visiting are the struct types being reflected, to detect recursion.
+	visiting map[reflect.Type]bool
+	// recursive are the struct types that refer to themselves, defined in defs.
+	recursive map[reflect.Type]bool
+	// names are the names of the recursive types in defs.
+	names map[reflect.Type]string
+	defs  map[string]Definition
+}
+
+func (r *reflector) reflect(t reflect.Type) (Definition, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case rawMessageType:
+		return Definition{}, nil
+	case timeType:
+		return Definition{Type: String, Format: "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Definition{Type: Boolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Definition{Type: Integer}, nil
+	case reflect.Float32, reflect.Float64:
+		return Definition{Type: Number}, nil
+	case reflect.String:
+		return Definition{Type: String}, nil
+	case reflect.Interface:
+		return Definition{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes []byte as a base64 string
+			return Definition{Type: String}, nil
+		}
+		items, err := r.reflect(t.Elem())
+		if err != nil {
+			return Definition{}, err
+		}
+		return Definition{Type: Array, Items: &items}, nil
+	case reflect.Map:
+		d := Definition{Type: Object}
+		if t.Elem().Kind() != reflect.Interface {
+			values, err := r.reflect(t.Elem())
+			if err != nil {
+				return Definition{}, err
+			}
+			d.AdditionalProperties = &values
+		}
+		return d, nil
+	case reflect.Struct:
+		return r.reflectStruct(t)
+	default:
+		return Definition{}, fmt.Errorf("jsonschema: unsupported type %s", t)
+	}
+}
+
+func (r *reflector) reflectStruct(t reflect.Type) (Definition, error) {
+	if r.visiting[t] {
+		if t == r.root {
+			return Definition{Ref: "#"}, nil
+		}
+		if t.Name() == "" {
+			return Definition{}, fmt.Errorf("%w: %s", ErrRecursiveType, t)
+		}
+		r.recursive[t] = true
+		return Definition{Ref: r.ref(t)}, nil
+	}
+	r.visiting[t] = true
+	defer delete(r.visiting, t)
+
+	d := Definition{
+		Type:       Object,
+		Properties: make(map[string]Definition),
+	}
+	if err := r.addFields(&d, t); err != nil {
+		return Definition{}, err
+	}
+
+	if r.recursive[t] {
+		if r.defs == nil {
+			r.defs = make(map[string]Definition)
+		}
+		r.defs[r.names[t]] = d
+		return Definition{Ref: r.ref(t)}, nil
+	}
+	return d, nil
+}
+
+// ref returns the reference to the definition of the recursive type t in defs,
+// naming it after the type.
+func (r *reflector) ref(t reflect.Type) string {
+	name, ok := r.names[t]
+	if !ok {
+		name = t.Name()
+		for i := 2; slices.Contains(slices.Collect(maps.Values(r.names)), name); i++ {
+			name = t.Name() + strconv.Itoa(i)
+		}
+		r.names[t] = name
+	}
+	return "#/$defs/" + name
+}
+
+// field is a struct field reflected as a property.
+type field struct {
+	reflect.StructField
+	// parent is the struct type that declares the field.
+	parent reflect.Type
+	name   string
+	opts   string
+	// depth is the depth of the field in the embedded structs.
+	depth int
+	// tagged reports whether the json tag names the field.
+	tagged bool
+}
+
+// addFields adds the fields of the struct type t to the properties of d,
+// promoting the fields of embedded structs.
+//
+// As with encoding/json, when several fields have the same name, the shallowest
+// one wins, then the one whose json tag names it. If that leaves more than one
+// field, none of them is added.
+func (r *reflector) addFields(d *Definition, t reflect.Type) error {
+	var fields []field
+	if err := r.collectFields(&fields, t, 0); err != nil {
+		return err
+	}
+
+	// the positions of the fields with each name
+	byName := make(map[string][]int)
+	for i, f := range fields {
+		byName[f.name] = append(byName[f.name], i)
+	}
+
+	for i, f := range fields {
+		if dominantField(fields, byName[f.name]) != i {
+			continue
+		}
+		prop, required, err := r.reflectField(f)
+		if err != nil {
+			return err
+		}
+		d.Properties[f.name] = prop
+		if required {
+			d.Required = append(d.Required, f.name)
+		}
+	}
+	return nil
+}
+
+// collectFields appends the fields of the struct type t at the given depth to
+// fields, and the fields of its embedded structs one level deeper.
+func (r *reflector) collectFields(fields *[]field, t reflect.Type, depth int) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if r.visiting[ft] {
+					return fmt.Errorf("%w: %s", ErrRecursiveType, ft)
+				}
+				r.visiting[ft] = true
+				err := r.collectFields(fields, ft, depth+1)
+				delete(r.visiting, ft)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		tagged := name != ""
+		if !tagged {
+			name = f.Name
+		}
+		*fields = append(*fields, field{
+			StructField: f,
+			parent:      t,
+			name:        name,
+			opts:        opts,
+			depth:       depth,
+			tagged:      tagged,
+		})
+	}
+	return nil
+}
+
+// dominantField returns the position of the field that wins among the fields
+// at the given positions, which have the same name, following the rules of
+// encoding/json. It returns -1 if there is none.
+func dominantField(fields []field, positions []int) int {
+	depth := fields[positions[0]].depth
+	for _, i := range positions {
+		depth = min(depth, fields[i].depth)
+	}
+
+	// a single tagged field wins, otherwise a single untagged one
+	var shallowest, tagged []int
+	for _, i := range positions {
+		if fields[i].depth != depth {
+			continue
+		}
+		shallowest = append(shallowest, i)
+		if fields[i].tagged {
+			tagged = append(tagged, i)
+		}
+	}
+	switch {
+	case len(tagged) == 1:
+		return tagged[0]
+	case len(tagged) == 0 && len(shallowest) == 1:
+		return shallowest[0]
+	}
+	return -1
+}
+
+// reflectField returns the property for the struct field f, and whether it is
+// required.
+func (r *reflector) reflectField(f field) (Definition, bool, error) {
+	t := f.parent
+
+	prop, err := r.reflect(f.Type)
+	if err != nil {
+		return Definition{}, false, fmt.Errorf("%s.%s: %w", t, f.Name, err)
+	}
+
+	required := f.Type.Kind() != reflect.Pointer && !hasOption(f.opts, "omitempty")
+
+	for _, opt := range splitTag(f.Tag.Get("jsonschema")) {
+		key, value, _ := strings.Cut(opt, "=")
+		switch key {
+		case "description":
+			prop.Description = value
+		case "enum":
+			prop.Enum = enumValues(prop, value)
+		case "required":
+			required = true
+		case "format":
+			prop.Format = value
+		case "pattern":
+			prop.Pattern = value
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return Definition{}, false, fmt.Errorf("jsonschema: %s.%s: invalid %s: %w", t, f.Name, key, err)
+			}
+			if key == "minimum" {
+				prop.Minimum = &n
+			} else {
+				prop.Maximum = &n
+			}
+		case "minLength", "maxLength":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return Definition{}, false, fmt.Errorf("jsonschema: %s.%s: invalid %s: %w", t, f.Name, key, err)
+			}
+			if key == "minLength" {
+				prop.MinLength = &n
+			} else {
+				prop.MaxLength = &n
+			}
+		case "default":
+			if json.Valid([]byte(value)) {
+				prop.Default = json.RawMessage(value)
+			} else {
+				prop.Default = value
+			}
+		default:
+			return Definition{}, false, fmt.Errorf("jsonschema: %s.%s: unknown jsonschema tag option %q", t, f.Name, key)
+		}
+	}
+
+	return prop, required, nil
+}
+
+// enumValues returns the values of an enum tag option for the property. The
+// values of a property that isn't a string are parsed as JSON, so an integer
+// property can have enum=1|2|3.
+func enumValues(prop Definition, tag string) []any {
+	values := strings.Split(tag, "|")
+	enum := make([]any, len(values))
+	for i, v := range values {
+		if !prop.HasType(String) && json.Valid([]byte(v)) {
+			enum[i] = json.RawMessage(v)
+		} else {
+			enum[i] = v
+		}
+	}
+	return enum
+}
+
+func hasOption(opts, opt string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTag splits a jsonschema tag on commas, except for escaped commas (\,).
+func splitTag(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			b.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(tag[i])
+		}
+	}
+	return append(parts, b.String())
+}
@@ -0,0 +1,110 @@
+package jsonschema
+
+import (
+	"maps"
+	"slices"
+)
+
+// Strict returns a copy of the definition that follows the rules of strict
+// structured outputs: every object requires all its properties and disallows
+// additional properties. A property that wasn't required becomes nullable
+// instead, so the model can still omit its value by returning null.
+//
+// The rules are applied to the nested definitions as well: properties, items,
+// compositions (anyOf, oneOf, allOf) and $defs.
+//
+// An object whose additionalProperties is a definition, such as one reflected
+// from a map, can't be expressed in strict mode: it disallows additional
+// properties as well, so only the empty object is allowed. Use SupportsStrict to
+// check for such objects first.
+func (d Definition) Strict() Definition {
+	s := d
+
+	if d.HasType(Object) || d.Properties != nil {
+		s.AdditionalProperties = false
+		s.Properties = make(map[string]Definition, len(d.Properties))
+		s.Required = slices.Sorted(maps.Keys(d.Properties))
+		for name, prop := range d.Properties {
+			prop = prop.Strict()
+			if !slices.Contains(d.Required, name) {
+				prop = prop.nullable()
+			}
+			s.Properties[name] = prop
+		}
+	}
+
+	if d.Items != nil {
+		items := d.Items.Strict()
+		s.Items = &items
+	}
+
+	s.AnyOf = strictAll(d.AnyOf)
+	s.OneOf = strictAll(d.OneOf)
+	s.AllOf = strictAll(d.AllOf)
+
+	if d.Defs != nil {
+		s.Defs = make(map[string]Definition, len(d.Defs))
+		for name, def := range d.Defs {
+			s.Defs[name] = def.Strict()
+		}
+	}
+
+	return s
+}
+
+// SupportsStrict reports whether Strict keeps the values the definition allows,
+// other than making optional properties nullable. It returns false if the
+// definition or a nested definition is an object whose additionalProperties is
+// a definition, such as one reflected from a map.
+func (d Definition) SupportsStrict() bool {
+	if _, ap := d.additionalProperties(); ap != nil {
+		return false
+	}
+	if d.Items != nil && !d.Items.SupportsStrict() {
+		return false
+	}
+	for _, defs := range [][]Definition{d.AnyOf, d.OneOf, d.AllOf} {
+		for _, def := range defs {
+			if !def.SupportsStrict() {
+				return false
+			}
+		}
+	}
+	for _, defs := range []map[string]Definition{d.Properties, d.Defs} {
+		for _, def := range defs {
+			if !def.SupportsStrict() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nullable returns a copy of the definition that also allows null.
+func (d Definition) nullable() Definition {
+	types := d.types()
+	switch {
+	case slices.Contains(types, Null):
+		return d
+	case d.Ref != "" || len(d.Enum) > 0 || d.Const != nil:
+		// the referenced definition, enum or const doesn't allow null
+		return Definition{AnyOf: []Definition{d, {Type: Null}}}
+	case len(types) == 0:
+		// a definition without a type allows null already
+		return d
+	}
+	d.Types = append(slices.Clone(types), Null)
+	d.Type = ""
+	return d
+}
+
+func strictAll(defs []Definition) []Definition {
+	if defs == nil {
+		return nil
+	}
+	s := make([]Definition, len(defs))
+	for i, d := range defs {
+		s[i] = d.Strict()
+	}
+	return s
+}
@@ -0,0 +1,409 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError is a single violation of a Definition by a JSON value.
+type ValidationError struct {
+	// Path is the JSON Pointer (RFC 6901) to the invalid value, for example
+	// "/items/2/name". The root value is "/".
+	Path string
+	// Message describes the violation, for example "expected string, got number".
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors are the violations of a Definition by a JSON value,
+// returned by Definition.Validate.
+//
+// The error message lists one violation per line, so it can be sent back to a
+// model as the result of a tool call, for the model to correct its arguments:
+//
+//	invalid arguments:
+//	/items/2/name: expected string, got number
+//	/state: expected one of "open", "closed", got "merged"
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid arguments:")
+	for _, err := range e {
+		b.WriteString("\n")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Validate checks that data is a JSON value that conforms to the definition.
+// It returns nil if it does, or ValidationErrors with all the violations.
+func (d Definition) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return ValidationErrors{{Path: "/", Message: "invalid JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return ValidationErrors{{Path: "/", Message: "invalid JSON: unexpected data after top-level value"}}
+	}
+
+	var errs ValidationErrors
+	vr := &validator{root: &d}
+	vr.validate(&d, "", v, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// maxRefDepth is the maximum number of references followed without descending
+// into the value, to stop on a reference cycle such as {"$ref": "#"}.
+const maxRefDepth = 32
+
+type validator struct {
+	// root is the definition that references are resolved against.
+	root *Definition
+	// refs is the number of references followed at the current path.
+	refs int
+}
+
+func (vr *validator) validate(d *Definition, path string, v any, errs *ValidationErrors) {
+	fail := func(format string, args ...any) {
+		p := path
+		if p == "" {
+			p = "/"
+		}
+		*errs = append(*errs, &ValidationError{Path: p, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if d.Ref != "" {
+		target := vr.resolve(d.Ref)
+		switch {
+		case target == nil:
+			fail("unresolved reference %q", d.Ref)
+		case vr.refs >= maxRefDepth:
+			fail("too many nested references at %q", d.Ref)
+		default:
+			vr.refs++
+			vr.validate(target, path, v, errs)
+			vr.refs--
+		}
+	}
+
+	if types := d.types(); len(types) > 0 && !slices.ContainsFunc(types, func(t DataType) bool { return isType(v, t) }) {
+		fail("expected %s, got %s", joinTypes(types), typeOf(v))
+		return
+	}
+
+	if len(d.Enum) > 0 && !slices.ContainsFunc(d.Enum, func(e any) bool { return equalJSON(v, e) }) {
+		fail("expected one of %s, got %s", marshalAll(d.Enum), describe(v))
+	}
+
+	if d.Const != nil && !equalJSON(v, d.Const) {
+		b, _ := json.Marshal(d.Const)
+		fail("expected %s, got %s", b, describe(v))
+	}
+
+	for i := range d.AllOf {
+		vr.validate(&d.AllOf[i], path, v, errs)
+	}
+	if len(d.AnyOf) > 0 && vr.matches(d.AnyOf, path, v) == 0 {
+		fail("expected a value matching at least one schema of anyOf")
+	}
+	if len(d.OneOf) > 0 {
+		if n := vr.matches(d.OneOf, path, v); n != 1 {
+			fail("expected a value matching exactly one schema of oneOf, matched %d", n)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range d.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		allowed, additional := d.additionalProperties()
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := path + "/" + escapePointer(name)
+			if prop, ok := d.Properties[name]; ok {
+				vr.scoped(&prop, p, v[name], errs)
+				continue
+			}
+			switch {
+			case !allowed:
+				fail("unexpected property %q", name)
+			case additional != nil:
+				vr.scoped(additional, p, v[name], errs)
+			}
+		}
+	case []any:
+		if d.Items != nil {
+			for i, item := range v {
+				vr.scoped(d.Items, path+"/"+strconv.Itoa(i), item, errs)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if d.MinLength != nil && n < *d.MinLength {
+			fail("expected at least %d characters, got %d", *d.MinLength, n)
+		}
+		if d.MaxLength != nil && n > *d.MaxLength {
+			fail("expected at most %d characters, got %d", *d.MaxLength, n)
+		}
+		if d.Pattern != "" {
+			re, err := regexp.Compile(d.Pattern)
+			switch {
+			case err != nil:
+				fail("invalid pattern %q: %v", d.Pattern, err)
+			case !re.MatchString(v):
+				fail("expected a string matching %q, got %s", d.Pattern, describe(v))
+			}
+		}
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			break
+		}
+		if d.Minimum != nil && f < *d.Minimum {
+			fail("expected a number >= %v, got %s", *d.Minimum, v)
+		}
+		if d.Maximum != nil && f > *d.Maximum {
+			fail("expected a number <= %v, got %s", *d.Maximum, v)
+		}
+	}
+}
+
+// scoped validates a value nested in the current one: references followed to
+// get to the current value don't count toward maxRefDepth.
+func (vr *validator) scoped(d *Definition, path string, v any, errs *ValidationErrors) {
+	refs := vr.refs
+	vr.refs = 0
+	vr.validate(d, path, v, errs)
+	vr.refs = refs
+}
+
+// matches returns the number of definitions that v conforms to.
+func (vr *validator) matches(defs []Definition, path string, v any) int {
+	n := 0
+	for i := range defs {
+		var errs ValidationErrors
+		vr.validate(&defs[i], path, v, &errs)
+		if len(errs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve returns the definition that ref points to, or nil. Only references
+// to the root ("#") and to its definitions ("#/$defs/name") are supported.
+func (vr *validator) resolve(ref string) *Definition {
+	if ref == "#" {
+		return vr.root
+	}
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil
+	}
+	name = strings.ReplaceAll(strings.ReplaceAll(name, "~1", "/"), "~0", "~")
+	d, ok := vr.root.Defs[name]
+	if !ok {
+		return nil
+	}
+	return &d
+}
+
+// types returns the data types allowed by the definition.
+func (d *Definition) types() []DataType {
+	if len(d.Types) > 0 {
+		return d.Types
+	}
+	if d.Type != "" {
+		return []DataType{d.Type}
+	}
+	return nil
+}
+
+func joinTypes(types []DataType) string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = string(t)
+	}
+	return strings.Join(s, " or ")
+}
+
+// equalJSON reports whether the decoded JSON value v is equal to the Go value c,
+// comparing numbers by value.
+func equalJSON(v, c any) bool {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var cv any
+	if err := dec.Decode(&cv); err != nil {
+		return false
+	}
+	return equalValues(v, cv)
+}
+
+func equalValues(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equalValues(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalValues(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aerr := a.Float64()
+		bf, berr := b.Float64()
+		if aerr != nil || berr != nil {
+			return a == b
+		}
+		return af == bf
+	default:
+		return a == b
+	}
+}
+
+// isType reports whether the decoded JSON value v is of type t.
+func isType(v any, t DataType) bool {
+	switch t {
+	case Object:
+		_, ok := v.(map[string]any)
+		return ok
+	case Array:
+		_, ok := v.([]any)
+		return ok
+	case String:
+		_, ok := v.(string)
+		return ok
+	case Number:
+		_, ok := v.(json.Number)
+		return ok
+	case Integer:
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		if _, err := n.Int64(); err == nil {
+			return true
+		}
+		// a number with a zero fractional part (1.0) is an integer
+		f, err := n.Float64()
+		return err == nil && f == float64(int64(f))
+	case Boolean:
+		_, ok := v.(bool)
+		return ok
+	case Null:
+		return v == nil
+	default:
+		return true
+	}
+}
+
+// typeOf returns the JSON type name of the decoded JSON value v.
+func typeOf(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return string(Object)
+	case []any:
+		return string(Array)
+	case string:
+		return string(String)
+	case json.Number:
+		return string(Number)
+	case bool:
+		return string(Boolean)
+	case nil:
+		return string(Null)
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// scalarString returns a string as is, the JSON text of a number, boolean or
+// null value, and an empty string for any other value.
+func scalarString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	default:
+		return ""
+	}
+}
+
+func describe(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case json.Number, bool, nil:
+		return scalarString(v)
+	default:
+		return typeOf(v)
+	}
+}
+
+// marshalAll returns the values as a comma separated list of JSON values.
+func marshalAll(values []any) string {
+	marshaled := make([]string, len(values))
+	for i, v := range values {
+		b, _ := json.Marshal(v)
+		marshaled[i] = string(b)
+	}
+	return strings.Join(marshaled, ", ")
+}
+
+// escapePointer escapes a property name for use in a JSON Pointer.
+func escapePointer(name string) string {
+	name = strings.ReplaceAll(name, "~", "~0")
+	return strings.ReplaceAll(name, "/", "~1")
+}
@@ -0,0 +1,80 @@
+package copilot
+
+import (
+	"context"
+	"time"
+)
+
+// Signature failure reasons reported to Metrics.SignatureFailure.
+const (
+	SignatureFailureMissingHeader = "missing_header"
+	SignatureFailureBodyTooLarge  = "body_too_large"
+	SignatureFailureReadBody      = "read_body"
+	SignatureFailureUnknownKey    = "unknown_key"
+	SignatureFailureKeyFetch      = "key_fetch"
+	SignatureFailureMalformed     = "malformed"
+	SignatureFailureInvalid       = "invalid"
+)
+
+// Metrics records metrics for agent and skill traffic and streaming health.
+//
+// Use WithMetrics to set the Metrics of a handler. The handler adds it to the
+// context passed to the agent or skill, so the Copilot API calls made with that
+// context are recorded too. The metrics package provides an implementation that
+// exposes the metrics with expvar or in the Prometheus text format.
+//
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// RequestReceived is called for each request a handler receives.
+	// handler is "agent" or "skill".
+	RequestReceived(handler string)
+	// SignatureFailure is called when a request's payload can't be verified.
+	// reason is one of the SignatureFailure constants.
+	SignatureFailure(reason string)
+	// ExecuteError is called when an agent or skill returns an error.
+	ExecuteError(handler string)
+	// StreamEvent is called for each SSE event written to a response.
+	StreamEvent(e StreamEvent)
+	// StreamDuration is called with the duration of each agent execution.
+	StreamDuration(d time.Duration)
+	// TimeToFirstDelta is called with the time from the start of an agent
+	// execution to the first delta written, if any delta was written.
+	TimeToFirstDelta(d time.Duration)
+	// UpstreamCompletion is called with the HTTP status code of each response
+	// from the Copilot API chat completions endpoint.
+	UpstreamCompletion(status int)
+}
+
+// WithMetrics sets the Metrics the handler records to.
+// By default, no metrics are recorded.
+func WithMetrics(m Metrics) HandlerOption {
+	return func(o *handlerOptions) {
+		o.metrics = m
+	}
+}
+
+// GetMetrics returns the Metrics from the context, or a Metrics that doesn't
+// record anything if the context doesn't have one.
+func GetMetrics(ctx context.Context) Metrics {
+	if val, ok := ctx.Value(metricsCtxKey).(Metrics); ok && val != nil {
+		return val
+	}
+	return noopMetrics{}
+}
+
+// AddMetrics adds the Metrics to the context.
+func AddMetrics(ctx context.Context, m Metrics) context.Context {
+	return context.WithValue(ctx, metricsCtxKey, m)
+}
+
+var metricsCtxKey = &contextKey{"Metrics"}
+
+type noopMetrics struct{}
+
+func (noopMetrics) RequestReceived(string)         {}
+func (noopMetrics) SignatureFailure(string)        {}
+func (noopMetrics) ExecuteError(string)            {}
+func (noopMetrics) StreamEvent(StreamEvent)        {}
+func (noopMetrics) StreamDuration(time.Duration)   {}
+func (noopMetrics) TimeToFirstDelta(time.Duration) {}
+func (noopMetrics) UpstreamCompletion(int)         {}
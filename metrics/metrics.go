@@ -0,0 +1,247 @@
+// Package metrics provides an implementation of copilot.Metrics that keeps the
+// metrics in memory and exposes them with expvar or in the Prometheus text
+// exposition format.
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/colbylwilliams/copilot-go"
+)
+
+// Metric names.
+const (
+	RequestsTotal            = "copilot_requests_total"
+	SignatureFailuresTotal   = "copilot_signature_failures_total"
+	ExecuteErrorsTotal       = "copilot_execute_errors_total"
+	StreamEventsTotal        = "copilot_stream_events_total"
+	StreamDurationSeconds    = "copilot_stream_duration_seconds"
+	TimeToFirstDeltaSeconds  = "copilot_stream_time_to_first_delta_seconds"
+	UpstreamCompletionsTotal = "copilot_upstream_completions_total"
+)
+
+// DefaultBuckets are the upper bounds (in seconds) of the histogram buckets,
+// unless configured with NewRecorder.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+var help = map[string]string{
+	RequestsTotal:            "Requests received, by handler.",
+	SignatureFailuresTotal:   "Requests with a payload that couldn't be verified, by reason.",
+	ExecuteErrorsTotal:       "Errors returned by agents and skills, by handler.",
+	StreamEventsTotal:        "SSE events written to responses, by kind.",
+	StreamDurationSeconds:    "Duration of agent executions.",
+	TimeToFirstDeltaSeconds:  "Time from the start of an agent execution to the first delta written.",
+	UpstreamCompletionsTotal: "Responses from the Copilot API chat completions endpoint, by status code.",
+}
+
+// Recorder is a copilot.Metrics that keeps the metrics in memory.
+// It is safe for concurrent use.
+type Recorder struct {
+	buckets []float64
+
+	mu         sync.Mutex
+	counters   map[string]map[string]uint64 // name -> label -> value
+	histograms map[string]*histogram
+}
+
+var _ copilot.Metrics = (*Recorder)(nil)
+
+// NewRecorder returns a new Recorder. If no buckets are given, the histograms
+// use DefaultBuckets.
+func NewRecorder(buckets ...float64) *Recorder {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Recorder{
+		buckets:    buckets,
+		counters:   make(map[string]map[string]uint64),
+		histograms: make(map[string]*histogram),
+	}
+}
+
+// RequestReceived implements copilot.Metrics.
+func (r *Recorder) RequestReceived(handler string) {
+	r.inc(RequestsTotal, label("handler", handler))
+}
+
+// SignatureFailure implements copilot.Metrics.
+func (r *Recorder) SignatureFailure(reason string) {
+	r.inc(SignatureFailuresTotal, label("reason", reason))
+}
+
+// ExecuteError implements copilot.Metrics.
+func (r *Recorder) ExecuteError(handler string) {
+	r.inc(ExecuteErrorsTotal, label("handler", handler))
+}
+
+// StreamEvent implements copilot.Metrics.
+func (r *Recorder) StreamEvent(e copilot.StreamEvent) {
+	r.inc(StreamEventsTotal, label("kind", string(e)))
+}
+
+// StreamDuration implements copilot.Metrics.
+func (r *Recorder) StreamDuration(d time.Duration) {
+	r.observe(StreamDurationSeconds, d.Seconds())
+}
+
+// TimeToFirstDelta implements copilot.Metrics.
+func (r *Recorder) TimeToFirstDelta(d time.Duration) {
+	r.observe(TimeToFirstDeltaSeconds, d.Seconds())
+}
+
+// UpstreamCompletion implements copilot.Metrics.
+func (r *Recorder) UpstreamCompletion(status int) {
+	r.inc(UpstreamCompletionsTotal, label("status", strconv.Itoa(status)))
+}
+
+// Handler returns a http.Handler that writes the metrics in the Prometheus
+// text exposition format.
+func (r *Recorder) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.WritePrometheus(w)
+	})
+}
+
+// WritePrometheus writes the metrics to w in the Prometheus text exposition format.
+func (r *Recorder) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+
+	for _, name := range sortedKeys(r.counters) {
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, help[name])
+		fmt.Fprintf(&b, "# TYPE %s counter\n", name)
+		values := r.counters[name]
+		for _, l := range sortedKeys(values) {
+			fmt.Fprintf(&b, "%s{%s} %d\n", name, l, values[l])
+		}
+	}
+
+	for _, name := range sortedKeys(r.histograms) {
+		h := r.histograms[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, help[name])
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		for i, le := range r.buckets {
+			fmt.Fprintf(&b, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(le), h.counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+		fmt.Fprintf(&b, "%s_sum %s\n", name, formatFloat(h.sum))
+		fmt.Fprintf(&b, "%s_count %d\n", name, h.count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Publish publishes the metrics with expvar under the given name, so they are
+// served (as JSON) by the expvar handler at /debug/vars.
+//
+// Like expvar.Publish, it panics if the name is already registered.
+func (r *Recorder) Publish(name string) {
+	expvar.Publish(name, expvar.Func(r.Snapshot))
+}
+
+// Snapshot returns a copy of the metrics, keyed by metric name. Counters are
+// maps of label to value, histograms are maps with the bucket counts, sum
+// and count.
+func (r *Recorder) Snapshot() any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := make(map[string]any, len(r.counters)+len(r.histograms))
+
+	for name, values := range r.counters {
+		c := make(map[string]uint64, len(values))
+		for l, v := range values {
+			c[l] = v
+		}
+		s[name] = c
+	}
+
+	for name, h := range r.histograms {
+		buckets := make(map[string]uint64, len(r.buckets))
+		for i, le := range r.buckets {
+			buckets[formatFloat(le)] = h.counts[i]
+		}
+		s[name] = map[string]any{
+			"buckets": buckets,
+			"sum":     h.sum,
+			"count":   h.count,
+		}
+	}
+
+	return s
+}
+
+func (r *Recorder) inc(name, label string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values, ok := r.counters[name]
+	if !ok {
+		values = make(map[string]uint64)
+		r.counters[name] = values
+	}
+	values[label]++
+}
+
+func (r *Recorder) observe(name string, v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.histograms[name]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(r.buckets))}
+		r.histograms[name] = h
+	}
+
+	// buckets are cumulative
+	for i, le := range r.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// label formats a Prometheus label pair.
+func label(name, value string) string {
+	return name + "=" + strconv.Quote(value)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
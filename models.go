@@ -0,0 +1,210 @@
+package copilot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultModelsTTL is how long the models listed by Client.Models are cached,
+// unless configured with WithModelsTTL.
+const DefaultModelsTTL = 10 * time.Minute
+
+// ErrUnsupportedModel is returned (wrapped) when a request uses a model that
+// isn't available, or a feature the model doesn't support.
+var ErrUnsupportedModel = errors.New("unsupported model")
+
+// Model types reported in ModelCapabilities.Type.
+const (
+	ModelTypeChat       = "chat"
+	ModelTypeEmbeddings = "embeddings"
+)
+
+// Model is a model available through the Copilot API.
+type Model struct {
+	ID           CopilotModel      `json:"id"`
+	Name         string            `json:"name,omitempty"`
+	Object       string            `json:"object,omitempty" default:"model"`
+	Vendor       string            `json:"vendor,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	Preview      bool              `json:"preview,omitempty"`
+	Capabilities ModelCapabilities `json:"capabilities"`
+}
+
+// ModelCapabilities are the capabilities of a Model.
+type ModelCapabilities struct {
+	Family    string        `json:"family,omitempty"`
+	Type      string        `json:"type,omitempty"`
+	Tokenizer string        `json:"tokenizer,omitempty"`
+	Limits    ModelLimits   `json:"limits"`
+	Supports  ModelSupports `json:"supports"`
+}
+
+// ModelLimits are the limits of a Model. A value of zero means the limit isn't
+// reported.
+type ModelLimits struct {
+	MaxContextWindowTokens int `json:"max_context_window_tokens,omitempty"`
+	MaxPromptTokens        int `json:"max_prompt_tokens,omitempty"`
+	MaxOutputTokens        int `json:"max_output_tokens,omitempty"`
+	MaxInputs              int `json:"max_inputs,omitempty"`
+}
+
+// ModelSupports are the features a Model supports.
+type ModelSupports struct {
+	Streaming         bool `json:"streaming,omitempty"`
+	ToolCalls         bool `json:"tool_calls,omitempty"`
+	ParallelToolCalls bool `json:"parallel_tool_calls,omitempty"`
+	StructuredOutputs bool `json:"structured_outputs,omitempty"`
+}
+
+// WithModelsTTL sets how long the models listed by Client.Models are cached.
+// A value of zero or less disables the cache. It defaults to DefaultModelsTTL.
+func WithModelsTTL(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.models.ttl = d
+	}
+}
+
+// WithModelValidation makes the client check every CompletionsRequest with
+// Client.CheckCompletionsRequest before sending it, so a request for a model
+// that isn't available, or that uses a feature the model doesn't support, fails
+// with ErrUnsupportedModel instead of an error status from the API.
+func WithModelValidation() ClientOption {
+	return func(c *Client) {
+		c.validateModels = true
+	}
+}
+
+// modelsCache caches the models listed for each token.
+type modelsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]modelsCacheEntry
+}
+
+type modelsCacheEntry struct {
+	models  []Model
+	expires time.Time
+}
+
+func (mc *modelsCache) get(key [sha256.Size]byte) ([]Model, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	e, ok := mc.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.models, true
+}
+
+func (mc *modelsCache) set(key [sha256.Size]byte, models []Model) {
+	if mc.ttl <= 0 {
+		return
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range mc.entries {
+		if now.After(e.expires) {
+			delete(mc.entries, k)
+		}
+	}
+	if mc.entries == nil {
+		mc.entries = make(map[[sha256.Size]byte]modelsCacheEntry)
+	}
+	mc.entries[key] = modelsCacheEntry{models: models, expires: now.Add(mc.ttl)}
+}
+
+// Models returns the models available to the token of the request (see
+// WithTokenSource). The list is cached per token for the client's TTL (see
+// WithModelsTTL). The returned slice must not be modified.
+func (c *Client) Models(ctx context.Context) ([]Model, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the cache is keyed by a hash of the token, so tokens aren't kept in memory
+	key := sha256.Sum256([]byte(token))
+
+	if models, ok := c.models.get(key); ok {
+		return models, nil
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var list struct {
+		Data []Model `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode models response: %w", err)
+	}
+
+	c.models.set(key, list.Data)
+	return list.Data, nil
+}
+
+// Model returns the model with the given ID, if it's available to the token of
+// the request. It returns an error wrapping ErrUnsupportedModel if it isn't.
+func (c *Client) Model(ctx context.Context, id CopilotModel) (*Model, error) {
+	models, err := c.Models(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range models {
+		if models[i].ID == id {
+			m := models[i]
+			return &m, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s is not available", ErrUnsupportedModel, id)
+}
+
+// CheckCompletionsRequest checks that the model of r is available and supports
+// the features r uses: chat completions, streaming, (parallel) tool calls and
+// structured outputs. It returns an error wrapping ErrUnsupportedModel if it
+// doesn't, or the error listing the models. A request without a model isn't
+// checked.
+func (c *Client) CheckCompletionsRequest(ctx context.Context, r CompletionsRequest) error {
+	if r.Model == "" {
+		return nil
+	}
+
+	m, err := c.Model(ctx, r.Model)
+	if err != nil {
+		return err
+	}
+
+	caps := m.Capabilities
+	switch {
+	case caps.Type != "" && caps.Type != ModelTypeChat:
+		return fmt.Errorf("%w: %s is not a chat model (type %s)", ErrUnsupportedModel, m.ID, caps.Type)
+	case r.Stream && !caps.Supports.Streaming:
+		return fmt.Errorf("%w: %s doesn't support streaming", ErrUnsupportedModel, m.ID)
+	case len(r.Tools) > 0 && !caps.Supports.ToolCalls:
+		return fmt.Errorf("%w: %s doesn't support tool calls", ErrUnsupportedModel, m.ID)
+	case r.ParallelToolCalls != nil && *r.ParallelToolCalls && !caps.Supports.ParallelToolCalls:
+		return fmt.Errorf("%w: %s doesn't support parallel tool calls", ErrUnsupportedModel, m.ID)
+	case r.ResponseFormat != nil && r.ResponseFormat.Type == ResponseFormatJSONSchema && !caps.Supports.StructuredOutputs:
+		return fmt.Errorf("%w: %s doesn't support structured outputs", ErrUnsupportedModel, m.ID)
+	}
+
+	return nil
+}
@@ -6,12 +6,9 @@ import (
 	"crypto/x509"
 	"encoding/asn1"
 	"encoding/base64"
-	"encoding/json"
 	"encoding/pem"
 	"errors"
-	"fmt"
 	"math/big"
-	"net/http"
 	"strings"
 )
 
@@ -20,13 +17,29 @@ type PayloadVerifier interface {
 	Verify(body []byte, sig string) (bool, error)
 }
 
-// NewVerifier returns a new Verifier.
-func NewPayloadVerifier() (PayloadVerifier, error) {
-	k, err := fetchPublicKey()
-	if err != nil {
-		return nil, err
-	}
-	return &verifier{k}, nil
+// PayloadKeyVerifier is a PayloadVerifier that can verify a payload with the
+// public key named by the Github-Public-Key-Identifier header. AgentHandler uses
+// VerifyKey instead of Verify when the verifier implements it.
+type PayloadKeyVerifier interface {
+	PayloadVerifier
+	VerifyKey(body []byte, sig string, keyID string) (bool, error)
+}
+
+// ErrUnknownPublicKey is returned when a payload is signed with a public key
+// that GitHub hasn't published (or that hasn't been fetched yet).
+var ErrUnknownPublicKey = errors.New("unknown public key identifier")
+
+// ErrPublicKeyFetch is returned when the public keys are fetched again to find
+// the key a payload is signed with, and the fetch fails.
+var ErrPublicKeyFetch = errors.New("public keys unavailable")
+
+// NewPayloadVerifier returns a new PayloadVerifier that verifies payloads with
+// the public keys GitHub publishes for Copilot. See NewKeyVerifier for details.
+//
+// The returned verifier refreshes its keys in the background for the lifetime
+// of the process. Use NewKeyVerifier to be able to stop it.
+func NewPayloadVerifier(opts ...VerifierOption) (PayloadVerifier, error) {
+	return NewKeyVerifier(opts...)
 }
 
 // NewPayloadVerifierWithKey returns a new Verifier with the given public key.
@@ -44,6 +57,11 @@ type verifier struct {
 
 // Verify checks if the payload is valid.
 func (a *verifier) Verify(data []byte, sig string) (bool, error) {
+	return verifySignature(a.pubKey, data, sig)
+}
+
+// verifySignature checks the base64 encoded ASN.1 signature of data against key.
+func verifySignature(key *ecdsa.PublicKey, data []byte, sig string) (bool, error) {
 	// Parse the Signature
 	parsedSig := asn1Signature{}
 	asnSig, err := base64.StdEncoding.DecodeString(sig)
@@ -57,7 +75,7 @@ func (a *verifier) Verify(data []byte, sig string) (bool, error) {
 
 	// Verify the SHA256 encoded payload against the signature with GitHub's Key
 	digest := sha256.Sum256(data)
-	keyOk := ecdsa.Verify(a.pubKey, digest[:], parsedSig.R, parsedSig.S)
+	keyOk := ecdsa.Verify(key, digest[:], parsedSig.R, parsedSig.S)
 
 	return keyOk, nil
 }
@@ -85,42 +103,6 @@ func parsePubKey(pubKey string) (*ecdsa.PublicKey, error) {
 	return ecdsaKey, nil
 }
 
-func fetchPublicKey() (*ecdsa.PublicKey, error) {
-	resp, err := http.Get("https://api.github.com/meta/public_keys/copilot_api")
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch public key: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch public key: %s", resp.Status)
-	}
-
-	var respBody struct {
-		PublicKeys []struct {
-			Identifier string `json:"key_identifier"`
-			Key        string `json:"key"`
-			IsCurrent  bool   `json:"is_current"`
-		} `json:"public_keys"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
-		return nil, fmt.Errorf("failed to decode public key: %w", err)
-	}
-
-	var rawKey string
-	for _, pk := range respBody.PublicKeys {
-		if pk.IsCurrent {
-			rawKey = pk.Key
-			break
-		}
-	}
-	if rawKey == "" {
-		return nil, fmt.Errorf("could not find current public key")
-	}
-
-	return parsePubKey(rawKey)
-}
-
 // asn1Signature is a struct for ASN.1 serializing/parsing signatures.
 type asn1Signature struct {
 	R *big.Int
@@ -0,0 +1,111 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides whether a failed request to the Copilot API is retried.
+//
+// A request is only retried before its response is returned to the caller: when
+// it couldn't be sent, or the API responded with an error status. Once a
+// (streamed) response with status 200 OK is returned, it is never retried, so a
+// user never sees the same output twice.
+type RetryPolicy interface {
+	// Retry is called after the given attempt (starting at 1) failed with err,
+	// which is an *APIError if the API responded with an error status. It returns
+	// how long to wait before the next attempt, and false if the request
+	// shouldn't be retried.
+	Retry(attempt int, err error) (time.Duration, bool)
+}
+
+// RetryPolicyFunc is an adapter to allow the use of ordinary functions as a RetryPolicy.
+type RetryPolicyFunc func(attempt int, err error) (time.Duration, bool)
+
+// Retry calls f(attempt, err).
+func (f RetryPolicyFunc) Retry(attempt int, err error) (time.Duration, bool) {
+	return f(attempt, err)
+}
+
+// DefaultRetryPolicy is the RetryPolicy of a Client, unless configured with
+// WithRetryPolicy.
+var DefaultRetryPolicy RetryPolicy = &ExponentialBackoff{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// ExponentialBackoff is a RetryPolicy that retries transient errors with a
+// jittered exponential backoff. Transient errors are errors sending the request
+// and the statuses 408, 429, 500, 502, 503 and 504.
+//
+// The delay before attempt n+1 is a random duration between half and all of
+// BaseDelay * 2^(n-1), capped at MaxDelay. If the response has a Retry-After
+// header, its value is used instead, unless it exceeds MaxDelay, in which case
+// the request isn't retried.
+type ExponentialBackoff struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, before jitter.
+	BaseDelay time.Duration
+	// MaxDelay is the maximum delay before a retry. Zero means no maximum.
+	MaxDelay time.Duration
+}
+
+// Retry implements RetryPolicy.
+func (b *ExponentialBackoff) Retry(attempt int, err error) (time.Duration, bool) {
+	if attempt >= b.MaxAttempts || !IsTransient(err) {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if b.MaxDelay > 0 && apiErr.RetryAfter > b.MaxDelay {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+
+	d := b.BaseDelay << (attempt - 1)
+	if d < 0 || (b.MaxDelay > 0 && d > b.MaxDelay) {
+		d = b.MaxDelay
+	}
+	if d <= 0 {
+		return 0, true
+	}
+	return d/2 + rand.N(d/2+1), true
+}
+
+// IsTransient reports whether err is an error from a request to the Copilot API
+// that may succeed if retried: an error sending the request (other than the
+// cancellation of its context) or a 408, 429, 500, 502, 503 or 504 status.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// the request couldn't be sent, or the response couldn't be read
+		return true
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithRetryPolicy sets the RetryPolicy of the client. A nil policy disables
+// retries. It defaults to DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
@@ -1,8 +1,10 @@
 package copilot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"regexp"
 	"strconv"
 	"strings"
@@ -44,6 +46,12 @@ func (req *Request) GetSessionMessage() *Message {
 // current url, the relevant repository, agent details, and the associated issue
 // or pull request if the current url is a valid issue or pull request url
 func (req *Request) GetSessionInfo() (*SessionInfo, error) {
+	return req.GetSessionInfoContext(context.Background())
+}
+
+// GetSessionInfoContext is like GetSessionInfo, but logs its diagnostics to the
+// logger in the context (see GetLogger) at the debug level.
+func (req *Request) GetSessionInfoContext(ctx context.Context) (*SessionInfo, error) {
 	// iterate over the messages in reverse order
 	// var session *Message
 	var url *ReferenceDataGitHubCurrentUrl
@@ -51,7 +59,9 @@ func (req *Request) GetSessionInfo() (*SessionInfo, error) {
 	var repo *ReferenceDataGitHubRepository
 	var agent *ReferenceDataGitHubAgent
 
-	fmt.Println("resolving session info")
+	log := GetLogger(ctx)
+
+	log.DebugContext(ctx, "resolving session info")
 
 	for i := len(req.Messages) - 1; i >= 0; i-- {
 		msg := req.Messages[i]
@@ -85,7 +95,7 @@ func (req *Request) GetSessionInfo() (*SessionInfo, error) {
 			// resolve the session url context
 			if msg.IsSessionMessage() {
 				// session = msg
-				if urlData := cetCurrentURLData(msg); urlData != nil {
+				if urlData := cetCurrentURLData(ctx, log, msg); urlData != nil {
 					url = urlData
 					if itemRefData, err := resolveRepoItemRef(url.URL); err == nil {
 						item = itemRefData
@@ -114,19 +124,19 @@ func (req *Request) GetSessionInfo() (*SessionInfo, error) {
 	if url == nil {
 		// this will happen if the user is not using the web (dotcom)
 		// chat interface, or if the current url reference is redacted
-		fmt.Println(" - no session url context found")
+		log.DebugContext(ctx, "no session url context found")
 	}
 
 	if item == nil {
 		// item will ONLY have a value (potentially) if url has a value
 		// and that url value is a valid issue or pull request url
-		fmt.Println(" - no session issue or pull request context found")
+		log.DebugContext(ctx, "no session issue or pull request context found")
 	}
 
 	if repo == nil {
 		// repo may be nil if the user is not interacting in the context
 		// of a repository, or if the current repository reference is redacted
-		fmt.Println(" - no session repo context found")
+		log.DebugContext(ctx, "no session repo context found")
 	}
 
 	if agent == nil {
@@ -184,7 +194,7 @@ func (req *Request) GetSessionInfo() (*SessionInfo, error) {
 }
 
 // cetCurrentURLData returns the current url reference data from the _session message
-func cetCurrentURLData(msg *Message) *ReferenceDataGitHubCurrentUrl {
+func cetCurrentURLData(ctx context.Context, log *slog.Logger, msg *Message) *ReferenceDataGitHubCurrentUrl {
 	// we only care about the current url reference
 	// on the _session message that dotcom sends
 	if !msg.IsSessionMessage() {
@@ -200,7 +210,7 @@ func cetCurrentURLData(msg *Message) *ReferenceDataGitHubCurrentUrl {
 		case *ReferenceDataGitHubRedacted:
 			// the current URL reference may be redacted
 			if d.Type == ReferenceTypeGitHubCurrentUrl {
-				fmt.Println("warning: current URL reference is redacted")
+				log.DebugContext(ctx, "current URL reference is redacted")
 				return nil
 			}
 		}
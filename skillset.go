@@ -0,0 +1,198 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/colbylwilliams/copilot-go/jsonschema"
+)
+
+// Skill is a single skill in a Copilot skillset.
+//
+// Unlike an Agent, a skill doesn't receive the chat messages. Copilot sends
+// the skill's parameters (as described by the skill's JSON Schema in the
+// GitHub App settings) as the request body, and the skill responds with a
+// plain JSON result that Copilot uses to generate the reply.
+type Skill[T any] interface {
+	Execute(ctx context.Context, token string, params *T) (any, error)
+}
+
+// SkillFunc is an adapter to allow the use of ordinary functions as a Skill.
+type SkillFunc[T any] func(ctx context.Context, token string, params *T) (any, error)
+
+// Execute calls f(ctx, token, params).
+func (f SkillFunc[T]) Execute(ctx context.Context, token string, params *T) (any, error) {
+	return f(ctx, token, params)
+}
+
+// SkillsetHandler returns a http.HandlerFunc that verifies the request payload
+// with v, decodes the JSON body into a new T, and writes the result of the skill
+// as a JSON response.
+//
+// Requests are rejected the same way as with NewAgentHandler.
+func SkillsetHandler[T any](v PayloadVerifier, s Skill[T], opts ...HandlerOption) http.HandlerFunc {
+	return skillsetHandler(v, s, nil, opts)
+}
+
+// skillsetHandler returns the handler for SkillsetHandler. If params isn't nil,
+// the request body is validated against it before it's decoded.
+func skillsetHandler[T any](v PayloadVerifier, s Skill[T], params *jsonschema.Definition, opts []HandlerOption) http.HandlerFunc {
+	o := newHandlerOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, reqSpan := o.tracer.Start(r.Context(), "copilot.skill")
+		defer reqSpan.End()
+
+		r = o.withLogger(r.WithContext(ctx))
+
+		o.metrics.RequestReceived("skill")
+
+		b, ok := o.verify(v, w, r)
+		if !ok {
+			return
+		}
+
+		token, err := getRequiredHeader(r, GitHubTokenHeader)
+		if err != nil {
+			o.fail(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if len(b) == 0 {
+			b = []byte("{}")
+		}
+
+		_, span := o.tracer.Start(r.Context(), "copilot.decode")
+		if params != nil {
+			err = params.Validate(b)
+		}
+		args := new(T)
+		if err == nil {
+			err = json.Unmarshal(b, args)
+		}
+		endSpan(span, err)
+		if err != nil {
+			o.fail(w, r, http.StatusBadRequest, fmt.Errorf("failed to unmarshal skill parameters: %w", err))
+			return
+		}
+
+		ctx = AddGetHubToken(r.Context(), token)
+		ctx = AddMetrics(ctx, o.metrics)
+
+		ctx, span = o.tracer.Start(ctx, "copilot.execute")
+
+		rw := &responseWriter{ResponseWriter: w, metrics: o.metrics}
+
+		res, err := s.Execute(ctx, token, args)
+		endSpan(span, err)
+		if err != nil {
+			o.metrics.ExecuteError("skill")
+			o.fail(rw, r, http.StatusInternalServerError, fmt.Errorf("failed to execute skill: %w", err))
+			return
+		}
+
+		if err := writeJSON(rw, http.StatusOK, res); err != nil {
+			o.fail(rw, r, http.StatusInternalServerError, fmt.Errorf("failed to write skill response: %w", err))
+		}
+	}
+}
+
+// SkillDefinition describes a skill as it is configured in the Copilot settings
+// of the GitHub App.
+type SkillDefinition struct {
+	// Name is the name of the skill.
+	Name string `json:"name"`
+	// InferenceDescription is the description Copilot uses to decide when to
+	// call the skill.
+	InferenceDescription string `json:"inference_description"`
+	// URL is the URL Copilot sends the skill's requests to.
+	URL string `json:"url"`
+	// Parameters is the JSON Schema for the skill's parameters.
+	Parameters jsonschema.Definition `json:"parameters"`
+}
+
+// Skillset is a registry of skills. It serves a route for each of its skills
+// and generates the skill definitions to configure in the GitHub App settings,
+// so the two are always built from the same source.
+//
+// Use AddSkill to register skills with the skillset.
+type Skillset struct {
+	v      PayloadVerifier
+	opts   []HandlerOption
+	mux    *http.ServeMux
+	skills []*SkillDefinition
+}
+
+// NewSkillset returns a new Skillset that verifies request payloads with v.
+// The options are applied to the handler of every skill.
+func NewSkillset(v PayloadVerifier, opts ...HandlerOption) *Skillset {
+	return &Skillset{
+		v:    v,
+		opts: opts,
+		mux:  http.NewServeMux(),
+	}
+}
+
+// AddSkill registers a skill with the skillset. The skill is served at
+// POST /{name}, relative to where the skillset is mounted. Requests with a body
+// that doesn't conform to params are rejected with 400 Bad Request.
+//
+// AddSkill panics if name is empty or a skill with the same name is already
+// registered with the skillset.
+func AddSkill[T any](s *Skillset, name, description string, params jsonschema.Definition, skill Skill[T]) {
+	if name == "" {
+		panic("copilot: skill name must not be empty")
+	}
+	if s.Skill(name) != nil {
+		panic(fmt.Sprintf("copilot: multiple registrations for skill %s", name))
+	}
+
+	s.skills = append(s.skills, &SkillDefinition{
+		Name:                 name,
+		InferenceDescription: description,
+		Parameters:           params,
+	})
+
+	s.mux.Handle("POST /"+name, skillsetHandler(s.v, skill, &params, s.opts))
+}
+
+// Skill returns the definition of the skill with the given name, or nil if
+// no skill with that name is registered. The URL of the returned definition
+// is not set.
+func (s *Skillset) Skill(name string) *SkillDefinition {
+	for _, d := range s.skills {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// Definitions returns the definitions of the registered skills, in the order
+// they were added, with each URL resolved against baseURL. baseURL should be the
+// public URL the skillset is mounted at, for example:
+//
+//	https://my_unique_devtunnelid-3333.use2.devtunnels.ms/skills
+func (s *Skillset) Definitions(baseURL string) []*SkillDefinition {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	defs := make([]*SkillDefinition, 0, len(s.skills))
+	for _, d := range s.skills {
+		def := *d
+		def.URL = baseURL + "/" + d.Name
+		defs = append(defs, &def)
+	}
+	return defs
+}
+
+// ServeHTTP dispatches the request to the skill matching the request path.
+//
+// To mount the skillset under a prefix, use http.StripPrefix:
+//
+//	mux.Handle("/skills/", http.StripPrefix("/skills", skillset))
+func (s *Skillset) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
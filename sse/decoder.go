@@ -0,0 +1,168 @@
+package sse
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/colbylwilliams/copilot-go"
+	"github.com/colbylwilliams/copilot-go/internal/ssewire"
+)
+
+// EventType is the type of an Event read by a Decoder.
+type EventType int
+
+const (
+	// EventUnknown is an event with a name the Decoder doesn't know. Its data
+	// isn't decoded.
+	EventUnknown EventType = iota
+	// EventResponse is a completions chunk, decoded into Event.Response.
+	EventResponse
+	// EventReferences is a copilot_references event, decoded into Event.References.
+	EventReferences
+	// EventConfirmation is a copilot_confirmation event, decoded into Event.Confirmation.
+	EventConfirmation
+	// EventErrors is a copilot_errors event, decoded into Event.Errors.
+	EventErrors
+	// EventDone is the [DONE] message that ends the stream.
+	EventDone
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventResponse:
+		return "response"
+	case EventReferences:
+		return sseEventNameReferences
+	case EventConfirmation:
+		return sseEventNameConfirmation
+	case EventErrors:
+		return sseEventNameErrors
+	case EventDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is an event of a Copilot or agent event stream, read by a Decoder.
+type Event struct {
+	// Type is the type of the event, which determines the field its data is
+	// decoded into.
+	Type EventType
+	// Name is the SSE event name, or empty for the default event.
+	Name string
+	// ID is the last event ID set in the stream.
+	ID string
+	// Data is the raw data of the event.
+	Data []byte
+
+	Response     *copilot.Response
+	References   []*copilot.Reference
+	Confirmation *copilot.Confirmation
+	Errors       []*copilot.Error
+}
+
+// Decoder reads typed events from a Copilot or agent event stream, such as a
+// completions response from the Copilot API, or the response of another agent.
+//
+// The stream is parsed following the SSE specification: data split across
+// multiple lines, comments, id and retry fields, and events with unknown names
+// (returned as EventUnknown) are all handled.
+type Decoder struct {
+	r    *ssewire.Reader
+	done bool
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: ssewire.NewReader(r)}
+}
+
+// Retry returns the reconnection time set by the last retry field of the
+// stream, or zero if the stream didn't set one.
+func (d *Decoder) Retry() time.Duration {
+	return time.Duration(d.r.Retry) * time.Millisecond
+}
+
+// Next returns the next event in the stream. After the EventDone event, or at
+// the end of the stream, it returns io.EOF.
+//
+// If the data of an event can't be decoded, Next returns a
+// *copilot.MalformedChunkError, and the next call to Next continues with the
+// following event. Any other error is final.
+func (d *Decoder) Next() (*Event, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+
+	ev, err := d.r.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Event{
+		Name: ev.Name,
+		ID:   ev.ID,
+		Data: ev.Data,
+	}
+
+	var v any
+	switch ev.Name {
+	case "", "message":
+		if strings.TrimSpace(string(ev.Data)) == "[DONE]" {
+			d.done = true
+			e.Type = EventDone
+			return e, nil
+		}
+		e.Type = EventResponse
+		e.Response = new(copilot.Response)
+		v = e.Response
+	case sseEventNameReferences:
+		e.Type = EventReferences
+		v = &e.References
+	case sseEventNameConfirmation:
+		e.Type = EventConfirmation
+		v = &e.Confirmation
+	case sseEventNameErrors:
+		e.Type = EventErrors
+		v = &e.Errors
+	default:
+		e.Type = EventUnknown
+		return e, nil
+	}
+
+	if err := json.Unmarshal(ev.Data, v); err != nil {
+		return nil, &copilot.MalformedChunkError{Event: ev.Name, Data: ev.Data, Err: err}
+	}
+
+	return e, nil
+}
+
+// All returns an iterator over the events in the stream, including the
+// EventDone event. A malformed event is yielded as a *copilot.MalformedChunkError,
+// and iteration continues with the following event; any other error is yielded
+// last.
+func (d *Decoder) All() iter.Seq2[*Event, error] {
+	return func(yield func(*Event, error) bool) {
+		for {
+			e, err := d.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				var mce *copilot.MalformedChunkError
+				if !yield(nil, err) || !errors.As(err, &mce) {
+					return
+				}
+				continue
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
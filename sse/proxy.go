@@ -0,0 +1,192 @@
+package sse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+
+	"github.com/colbylwilliams/copilot-go"
+)
+
+// Transformer transforms the events of a stream proxied with Proxy.
+//
+// Transform returns the events to write in place of e: e itself (possibly
+// modified), nothing to drop it, or more events to inject some. The events are
+// passed to the next transformer of the chain, if any.
+type Transformer interface {
+	Transform(ctx context.Context, e *Event) ([]*Event, error)
+}
+
+// TransformerFunc is an adapter to allow the use of ordinary functions as a Transformer.
+type TransformerFunc func(ctx context.Context, e *Event) ([]*Event, error)
+
+// Transform calls f(ctx, e).
+func (f TransformerFunc) Transform(ctx context.Context, e *Event) ([]*Event, error) {
+	return f(ctx, e)
+}
+
+// Proxy reads the events of the upstream stream r, such as a completions
+// response from Client.ChatCompletionsStream or the response of another agent,
+// passes them through the chain of transformers, and writes the resulting
+// events to s.
+//
+// Writing to s stamps every chunk with the stream's response ID and creation
+// time, so the upstream IDs are replaced. Events with unknown names and
+// malformed events are dropped.
+//
+// The EventDone event (added if the upstream stream ends without it) is passed
+// through the transformers, so they can inject events at the end of the stream,
+// but it isn't written. Proxy returns at the end of the upstream stream without
+// stopping s, so the caller can write more before closing it.
+//
+// The upstream finish reasons (stop, length, content_filter, tool_calls, ...)
+// are passed through the transformers, so they can react to them, for example
+// by injecting an error when the response was cut off, but they aren't written:
+// s ends the response with its own stop finish reason. A chunk left without
+// content, tool calls or any other payload, such as one that only had a finish
+// reason, isn't written at all.
+func Proxy(ctx context.Context, s *Stream, r io.Reader, transformers ...Transformer) error {
+	log := copilot.GetLogger(ctx)
+
+	process := func(e *Event) error {
+		events := []*Event{e}
+		for _, t := range transformers {
+			var out []*Event
+			for _, e := range events {
+				te, err := t.Transform(ctx, e)
+				if err != nil {
+					return err
+				}
+				out = append(out, te...)
+			}
+			events = out
+		}
+
+		for _, e := range events {
+			if err := writeEvent(s, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	done := false
+	for e, err := range NewDecoder(r).All() {
+		var mce *copilot.MalformedChunkError
+		if errors.As(err, &mce) {
+			log.WarnContext(ctx, "dropping malformed upstream event", "event", mce.Event, "error", mce.Err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		done = e.Type == EventDone
+		if err := process(e); err != nil {
+			return err
+		}
+	}
+
+	if !done {
+		// the upstream stream ended without [DONE]
+		return process(&Event{Type: EventDone})
+	}
+	return nil
+}
+
+// writeEvent writes the typed event e to s.
+func writeEvent(s *Stream, e *Event) error {
+	switch e.Type {
+	case EventResponse:
+		if e.Response == nil {
+			return nil
+		}
+		r := *e.Response
+		r.Choices = nil
+		for _, c := range e.Response.Choices {
+			// s ends the response with its own finish reason
+			c.FinishReason = ""
+			if c.Delta.Content == "" && c.Delta.FunctionCall == nil && len(c.Delta.ToolCalls) == 0 &&
+				c.Logprobs == nil && c.ContentFilterResults == nil {
+				continue
+			}
+			r.Choices = append(r.Choices, c)
+		}
+		if len(r.Choices) == 0 && len(r.References) == 0 && r.Confirmation == nil && len(r.Errors) == 0 &&
+			r.Usage == nil && len(r.PromptFilterResults) == 0 {
+			return nil
+		}
+		return s.Response(r)
+	case EventReferences:
+		return s.References(e.References...)
+	case EventConfirmation:
+		if e.Confirmation == nil {
+			return nil
+		}
+		return s.Confirmation(e.Confirmation)
+	case EventErrors:
+		return s.Errors(e.Errors...)
+	default:
+		return nil
+	}
+}
+
+// RewriteContent returns a Transformer that replaces the delta content of every
+// completions chunk with the result of fn.
+func RewriteContent(fn func(content string) string) Transformer {
+	return TransformerFunc(func(_ context.Context, e *Event) ([]*Event, error) {
+		if e.Type == EventResponse && e.Response != nil {
+			for i := range e.Response.Choices {
+				d := &e.Response.Choices[i].Delta
+				if d.Content != "" {
+					d.Content = fn(d.Content)
+				}
+			}
+		}
+		return []*Event{e}, nil
+	})
+}
+
+// Redact returns a Transformer that replaces the matches of the patterns in the
+// delta content of every completions chunk with replacement, such as
+// "[REDACTED]".
+//
+// Each chunk is redacted on its own, so a match split across chunks isn't
+// redacted.
+func Redact(replacement string, patterns ...*regexp.Regexp) Transformer {
+	return RewriteContent(func(content string) string {
+		for _, re := range patterns {
+			content = re.ReplaceAllLiteralString(content, replacement)
+		}
+		return content
+	})
+}
+
+// InjectReferences returns a Transformer that writes the references before the
+// first event of the stream. A Transformer returned by InjectReferences must
+// only be used for one stream.
+func InjectReferences(refs ...*copilot.Reference) Transformer {
+	injected := false
+	return TransformerFunc(func(_ context.Context, e *Event) ([]*Event, error) {
+		if injected || len(refs) == 0 {
+			return []*Event{e}, nil
+		}
+		injected = true
+		return []*Event{{Type: EventReferences, Name: sseEventNameReferences, References: refs}, e}, nil
+	})
+}
+
+// InjectConfirmation returns a Transformer that writes the confirmation at the
+// end of the stream, before the EventDone event.
+func InjectConfirmation(c *copilot.Confirmation) Transformer {
+	return TransformerFunc(func(_ context.Context, e *Event) ([]*Event, error) {
+		if e.Type != EventDone {
+			return []*Event{e}, nil
+		}
+		return []*Event{{Type: EventConfirmation, Name: sseEventNameConfirmation, Confirmation: c}, e}, nil
+	})
+}
@@ -12,9 +12,9 @@ import (
 )
 
 const (
-	sseEventNameConfirmation string = "copilot_confirmation"
-	sseEventNameReferences   string = "copilot_references"
-	sseEventNameErrors       string = "copilot_errors"
+	sseEventNameConfirmation string = copilot.EventNameConfirmation
+	sseEventNameReferences   string = copilot.EventNameReferences
+	sseEventNameErrors       string = copilot.EventNameErrors
 )
 
 func flush(w io.Writer) {
@@ -23,6 +23,13 @@ func flush(w io.Writer) {
 	}
 }
 
+// record reports the event to w, if it implements copilot.StreamEventRecorder.
+func record(w io.Writer, e copilot.StreamEvent) {
+	if r, ok := w.(copilot.StreamEventRecorder); ok {
+		r.RecordStreamEvent(e)
+	}
+}
+
 // WriteDone writes a [DONE] SSE message to the writer and flushes the writer.
 //
 // example output:
@@ -33,6 +40,21 @@ func WriteDone(w io.Writer) {
 	flush(w)
 }
 
+// WriteComment writes an SSE comment to the writer and flushes the writer.
+// Clients ignore comments, so they can be used to keep the connection alive.
+// A comment must not contain newlines.
+//
+// example output:
+//
+//	: keep-alive
+func WriteComment(w io.Writer, comment string) error {
+	if _, err := w.Write([]byte(": " + comment + "\n\n")); err != nil {
+		return err
+	}
+	flush(w)
+	return nil
+}
+
 // WriteData writes a data SSE message to the writer and flushes the writer.
 func WriteData(w io.Writer, v any) error {
 	_, _ = w.Write([]byte("data: "))
@@ -85,7 +107,11 @@ func WriteErrors(w io.Writer, errs []*copilot.Error) error {
 	if len(errs) == 0 {
 		return nil
 	}
-	return WriteEventData(w, sseEventNameErrors, errs)
+	if err := WriteEventData(w, sseEventNameErrors, errs); err != nil {
+		return err
+	}
+	record(w, copilot.StreamEventError)
+	return nil
 }
 
 // WriteError writes error SSE events and data to the writer and flushes the writer.
@@ -125,7 +151,11 @@ func WriteReferences(w io.Writer, refs []*copilot.Reference) error {
 	if len(refs) == 0 {
 		return nil
 	}
-	return WriteEventData(w, sseEventNameReferences, refs)
+	if err := WriteEventData(w, sseEventNameReferences, refs); err != nil {
+		return err
+	}
+	record(w, copilot.StreamEventReference)
+	return nil
 }
 
 // WriteReference writes reference SSE events and data to the writer and flushes the writer.
@@ -164,7 +194,11 @@ func WriteReference(w io.Writer, ref *copilot.Reference) error {
 //		}
 //	}
 func WriteConfirmation(w io.Writer, c *copilot.Confirmation) error {
-	return WriteEventData(w, sseEventNameConfirmation, c)
+	if err := WriteEventData(w, sseEventNameConfirmation, c); err != nil {
+		return err
+	}
+	record(w, copilot.StreamEventConfirmation)
+	return nil
 }
 
 // WriteDelta writes a custom message to the writer and flushes the writer.
@@ -176,20 +210,30 @@ func WriteConfirmation(w io.Writer, c *copilot.Confirmation) error {
 // IMPORTANT: You must call WriteStop after the last message to ensure the chat
 // session is properly closed.
 //
+// Stream takes care of both for you.
+//
 // example output:
 //
 //	data: {"id": "123", "created": 1234567890, "choices": [{"delta": {"content": "Hello, world!", "role": "assistant"}}]}
 func WriteDelta(w io.Writer, id string, delta string) error {
-	return WriteData(w, copilot.Response{
+	return writeDelta(w, id, time.Now(), delta)
+}
+
+func writeDelta(w io.Writer, id string, created time.Time, delta string) error {
+	if err := WriteData(w, copilot.Response{
 		ID:      id,
-		Created: time.Now().UTC().Unix(),
+		Created: created.UTC().Unix(),
 		Choices: []copilot.ChatChoice{{
 			Delta: copilot.ChatChoiceDelta{
 				Content: delta,
 				Role:    string(copilot.ChatRoleAssistant),
 			},
 		}},
-	})
+	}); err != nil {
+		return err
+	}
+	record(w, copilot.StreamEventDelta)
+	return nil
 }
 
 // WriteStop writes stop SSE data to the writer and flushes the writer.
@@ -202,10 +246,16 @@ func WriteDelta(w io.Writer, id string, delta string) error {
 //
 // example output:
 //
-//	data: {"id": "123", "choices": [{"finish_reason": "stop"}]}
+//	data: {"id": "123", "created": 1234567890, "choices": [{"finish_reason": "stop"}]}
 //	data: [DONE]
 func WriteStop(w io.Writer, id string) error {
+	return writeStop(w, id, time.Now())
+}
+
+func writeStop(w io.Writer, id string, created time.Time) error {
 	if err := WriteData(w, copilot.Response{
+		ID:      id,
+		Created: created.UTC().Unix(),
 		Choices: []copilot.ChatChoice{{
 			FinishReason: copilot.ChatFinishReasonStop,
 		}},
@@ -213,6 +263,7 @@ func WriteStop(w io.Writer, id string) error {
 		return err
 	}
 	WriteDone(w)
+	record(w, copilot.StreamEventStop)
 	return nil
 }
 
@@ -0,0 +1,273 @@
+package sse
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/colbylwilliams/copilot-go"
+)
+
+// ErrStreamClosed is returned by the methods of a Stream that write to it after
+// it was stopped or closed.
+var ErrStreamClosed = errors.New("sse: stream closed")
+
+// MinHeartbeatInterval is the shortest heartbeat interval of a Stream. A shorter
+// interval passed to WithHeartbeat is raised to it.
+const MinHeartbeatInterval = time.Second
+
+// StreamOption configures a Stream.
+type StreamOption func(*Stream)
+
+// WithID sets the ID of the response the stream writes. It defaults to a new
+// random ID.
+func WithID(id string) StreamOption {
+	return func(s *Stream) {
+		s.id = id
+	}
+}
+
+// WithClock sets the function that returns the creation time of each chunk
+// the stream writes. It defaults to time.Now.
+func WithClock(now func() time.Time) StreamOption {
+	return func(s *Stream) {
+		s.now = now
+	}
+}
+
+// WithHeartbeat makes the stream write a comment (see WriteComment) whenever
+// nothing was written for the interval, to keep the connection alive while the
+// agent works, for example on tool calls before the first delta. The heartbeats
+// stop when the stream is stopped or closed, or ctx is done.
+//
+// An interval of zero or less disables the heartbeats, and an interval shorter
+// than MinHeartbeatInterval is raised to it.
+func WithHeartbeat(ctx context.Context, interval time.Duration) StreamOption {
+	return func(s *Stream) {
+		s.heartbeatCtx = ctx
+		s.heartbeat = interval
+		if interval > 0 {
+			s.heartbeat = max(interval, MinHeartbeatInterval)
+		}
+	}
+}
+
+// Stream writes a Copilot response to an http.ResponseWriter, following the
+// protocol that the free functions of this package leave to the caller:
+//
+//   - the streaming headers are set once, before the first write
+//   - every chunk has the same response ID, so the client attributes the
+//     response to the agent
+//   - the response ends with a stop message and [DONE], exactly once, after
+//     which writes return ErrStreamClosed
+//
+// A Stream is safe for concurrent use. Defer Close right after creating it, so
+// the response is ended even if the agent returns early or panics:
+//
+//	s := sse.NewStream(w)
+//	defer s.Close()
+//
+//	if err := s.Delta("Hello"); err != nil {
+//		return err
+//	}
+type Stream struct {
+	w   http.ResponseWriter
+	id  string
+	now func() time.Time
+
+	heartbeatCtx context.Context
+	heartbeat    time.Duration
+
+	mu        sync.Mutex
+	started   bool
+	closed    bool
+	err       error
+	lastWrite time.Time
+
+	// done is closed when the stream is stopped, to stop the heartbeats, and
+	// heartbeatDone when the heartbeat goroutine has returned.
+	done          chan struct{}
+	doneOnce      sync.Once
+	heartbeatDone chan struct{}
+}
+
+// NewStream returns a new Stream that writes to w.
+func NewStream(w http.ResponseWriter, opts ...StreamOption) *Stream {
+	s := &Stream{
+		w:         w,
+		now:       time.Now,
+		lastWrite: time.Now(),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.id == "" {
+		s.id = newID()
+	}
+	if s.heartbeat > 0 && s.heartbeatCtx != nil {
+		s.heartbeatDone = make(chan struct{})
+		go s.runHeartbeat()
+	}
+	return s
+}
+
+// runHeartbeat writes a heartbeat comment whenever the stream is idle for the
+// heartbeat interval, until the stream is closed or the context is done.
+func (s *Stream) runHeartbeat() {
+	defer close(s.heartbeatDone)
+
+	t := time.NewTicker(s.heartbeat / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.heartbeatCtx.Done():
+			return
+		case <-s.done:
+			return
+		case <-t.C:
+		}
+
+		s.mu.Lock()
+		// the select may pick the tick even if ctx is done, and the response
+		// must not be written to once the request's context is done
+		if s.closed || s.err != nil || s.heartbeatCtx.Err() != nil {
+			s.mu.Unlock()
+			return
+		}
+		if time.Since(s.lastWrite) >= s.heartbeat {
+			// written under the lock, so it's never interleaved with an event
+			s.writeHeaders()
+			s.err = WriteComment(s.w, "keep-alive")
+			s.lastWrite = time.Now()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// newID returns a new random response ID.
+func newID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+// ID returns the ID of the response.
+func (s *Stream) ID() string {
+	return s.id
+}
+
+// Closed reports whether the stream was stopped or closed.
+func (s *Stream) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// write calls fn to write to the response while holding the lock, after
+// setting the headers if this is the first write. A write error is returned by
+// every following write.
+func (s *Stream) write(fn func(w http.ResponseWriter) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+	s.writeHeaders()
+
+	s.err = fn(s.w)
+	s.lastWrite = time.Now()
+	return s.err
+}
+
+// writeHeaders sets the streaming headers, if it wasn't done yet. It must be
+// called while holding the lock.
+func (s *Stream) writeHeaders() {
+	if !s.started {
+		WriteStreamingHeaders(s.w)
+		s.started = true
+	}
+}
+
+// Delta writes content as a delta of the assistant message.
+func (s *Stream) Delta(content string) error {
+	return s.write(func(w http.ResponseWriter) error {
+		return writeDelta(w, s.id, s.now(), content)
+	})
+}
+
+// Response writes the chunk r, with its ID and creation time set to the
+// stream's.
+func (s *Stream) Response(r copilot.Response) error {
+	return s.write(func(w http.ResponseWriter) error {
+		r.ID = s.id
+		r.Created = s.now().UTC().Unix()
+		if err := WriteData(w, r); err != nil {
+			return err
+		}
+		for _, c := range r.Choices {
+			if c.Delta.Content != "" {
+				record(w, copilot.StreamEventDelta)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// References writes the references, if any.
+func (s *Stream) References(refs ...*copilot.Reference) error {
+	return s.write(func(w http.ResponseWriter) error {
+		return WriteReferences(w, refs)
+	})
+}
+
+// Confirmation writes the confirmation.
+func (s *Stream) Confirmation(c *copilot.Confirmation) error {
+	return s.write(func(w http.ResponseWriter) error {
+		return WriteConfirmation(w, c)
+	})
+}
+
+// Errors writes the errors, if any.
+func (s *Stream) Errors(errs ...*copilot.Error) error {
+	return s.write(func(w http.ResponseWriter) error {
+		return WriteErrors(w, errs)
+	})
+}
+
+// Stop ends the response with the stop message and [DONE]. Writes after Stop
+// return ErrStreamClosed, including another call to Stop.
+//
+// Stop stops the heartbeats, if any, and waits for them to stop, so nothing is
+// written to the response after Stop returns.
+func (s *Stream) Stop() error {
+	err := s.write(func(w http.ResponseWriter) error {
+		s.closed = true
+		return writeStop(w, s.id, s.now())
+	})
+
+	s.doneOnce.Do(func() { close(s.done) })
+	if s.heartbeatDone != nil {
+		<-s.heartbeatDone
+	}
+	return err
+}
+
+// Close ends the response like Stop, unless it was already stopped or closed,
+// in which case it does nothing and returns nil.
+func (s *Stream) Close() error {
+	if err := s.Stop(); !errors.Is(err, ErrStreamClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package copilot
+
+// StreamEvent is the kind of a Copilot SSE event written to a response.
+type StreamEvent string
+
+const (
+	StreamEventDelta        StreamEvent = "delta"
+	StreamEventReference    StreamEvent = "reference"
+	StreamEventConfirmation StreamEvent = "confirmation"
+	StreamEventError        StreamEvent = "error"
+	StreamEventStop         StreamEvent = "stop"
+)
+
+// StreamEventRecorder is implemented by the http.ResponseWriter the handlers pass
+// to agents. The functions in the sse package report each event they write to
+// it, which is how the handlers count the events written for tracing and
+// metrics.
+//
+// If an agent wraps the http.ResponseWriter, the wrapper should implement
+// StreamEventRecorder by calling the wrapped writer.
+type StreamEventRecorder interface {
+	RecordStreamEvent(e StreamEvent)
+}
+
+// Names of the SSE events Copilot uses for references, confirmations and errors.
+// Deltas are sent as unnamed (data only) events.
+const (
+	EventNameReferences   = "copilot_references"
+	EventNameConfirmation = "copilot_confirmation"
+	EventNameErrors       = "copilot_errors"
+)
@@ -0,0 +1,163 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/colbylwilliams/copilot-go/jsonschema"
+)
+
+// DefaultStructuredOutputRepairs is the number of times a structured output
+// request is repeated when the response doesn't conform to the schema, unless
+// configured with WithStructuredOutputRepairs.
+const DefaultStructuredOutputRepairs = 2
+
+// WithStructuredOutputRepairs sets the number of times Client.CompletionsJSON
+// asks the model to repair a response that doesn't conform to the schema.
+// Zero disables the repairs. It defaults to DefaultStructuredOutputRepairs.
+func WithStructuredOutputRepairs(n int) ClientOption {
+	return func(c *Client) {
+		c.structuredRepairs = n
+	}
+}
+
+// StructuredOutputError is returned by Client.CompletionsJSON and CompletionsAs
+// when the model's response still doesn't conform to the schema after the
+// repairs.
+type StructuredOutputError struct {
+	// Content is the content of the model's last response.
+	Content string
+	// Err is the validation error of the content.
+	Err error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return "structured output doesn't conform to the schema: " + e.Err.Error()
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+// CompletionsJSON sends a completions request whose response must be JSON that
+// conforms to schema, and returns that JSON.
+//
+// The request's response_format is set to the schema, named name, with the
+// strict rules of structured outputs applied (see jsonschema.Definition.Strict):
+// every property is required, and properties that weren't required are nullable.
+// If the schema doesn't support strict mode (see
+// jsonschema.Definition.SupportsStrict), for example because it has a map, it is
+// sent as is, with strict mode off, and the response is validated against it.
+// If the response doesn't conform to the schema, the validation errors are sent
+// back to the model to repair its response, as configured with
+// WithStructuredOutputRepairs. If it still doesn't, CompletionsJSON returns a
+// *StructuredOutputError.
+func (c *Client) CompletionsJSON(ctx context.Context, r CompletionsRequest, name string, schema jsonschema.Definition) (json.RawMessage, error) {
+	if name == "" {
+		name = "response"
+	}
+
+	strict := schema.SupportsStrict()
+	if strict {
+		schema = schema.Strict()
+	}
+	r.ResponseFormat = &ResponseFormat{
+		Type: ResponseFormatJSONSchema,
+		JSONSchema: &JSONSchemaFormat{
+			Name:        name,
+			Description: schema.Description,
+			Schema:      schema,
+			Strict:      strict,
+		},
+	}
+	r.Messages = append([]*Message(nil), r.Messages...)
+
+	for repairs := 0; ; repairs++ {
+		res, err := c.Completions(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		msg := res.Message()
+		if msg == nil {
+			return nil, errors.New("completions response has no choices")
+		}
+
+		content := trimCodeFence(msg.Content)
+		verr := schema.Validate([]byte(content))
+		if verr == nil {
+			return json.RawMessage(content), nil
+		}
+		if repairs >= c.structuredRepairs {
+			return nil, &StructuredOutputError{Content: msg.Content, Err: verr}
+		}
+
+		GetLogger(ctx).DebugContext(ctx, "repairing structured output", "schema", name, "error", verr)
+
+		r.Messages = append(r.Messages, msg, &Message{
+			Role:    ChatRoleUser,
+			Content: repairPrompt(name, verr),
+		})
+	}
+}
+
+// CompletionsAs sends a completions request whose response must be JSON that
+// conforms to the schema reflected from T (see jsonschema.Reflect), and returns
+// the response decoded into a new T. See Client.CompletionsJSON for details.
+//
+// If the response is strict, the fields of T that aren't required by the
+// schema (pointers and omitempty fields) may be null, and are then left zero.
+func CompletionsAs[T any](ctx context.Context, c *Client, r CompletionsRequest, name string) (*T, error) {
+	schema, err := jsonschema.Reflect[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := c.CompletionsJSON(ctx, r, name, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	v := new(T)
+	if err := json.Unmarshal(raw, v); err != nil {
+		return nil, fmt.Errorf("failed to decode structured output: %w", err)
+	}
+	return v, nil
+}
+
+// repairPrompt returns the message that asks the model to repair a response
+// that doesn't conform to the schema, listing the violations.
+func repairPrompt(name string, verr error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your response doesn't conform to the %s JSON schema:\n", name)
+	var errs jsonschema.ValidationErrors
+	if errors.As(verr, &errs) {
+		for _, e := range errs {
+			b.WriteString(e.Error())
+			b.WriteString("\n")
+		}
+	} else {
+		b.WriteString(verr.Error())
+		b.WriteString("\n")
+	}
+	b.WriteString("Respond again with only the corrected JSON.")
+	return b.String()
+}
+
+// trimCodeFence returns the content without surrounding whitespace and, if the
+// model wrapped it in a Markdown code block, without the code fence.
+func trimCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") || len(s) < 6 {
+		return s
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "```"), "```")
+	// drop the language of the code block, such as json
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
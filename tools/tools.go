@@ -0,0 +1,280 @@
+// Package tools runs the tool-calling loop of the Copilot API: Go functions are
+// registered as tools, the tool calls returned by the model are executed, and
+// their results are sent back to the model until it returns a final answer.
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/colbylwilliams/copilot-go"
+	"github.com/colbylwilliams/copilot-go/jsonschema"
+	"github.com/colbylwilliams/copilot-go/sse"
+)
+
+const (
+	// DefaultMaxIterations is the maximum number of requests a Runner sends to
+	// the model in one run, unless configured with WithMaxIterations.
+	DefaultMaxIterations = 10
+
+	// DefaultTimeout is the maximum duration of a run, unless configured with
+	// WithTimeout.
+	DefaultTimeout = 2 * time.Minute
+)
+
+// ErrMaxIterations is returned by Runner.Run when the model still returns tool
+// calls after the maximum number of iterations.
+var ErrMaxIterations = errors.New("tools: maximum number of iterations reached")
+
+// Func is the function that runs a tool. args are the (JSON) arguments the model
+// called the tool with. The result is sent back to the model as the content of
+// the tool message: a string is sent as is, any other value is marshaled to JSON.
+//
+// The arguments are validated against the tool's parameters before Func is
+// called. If they are invalid, or Func returns an error, the error message is sent
+// back to the model as the result, so it can correct itself.
+type Func func(ctx context.Context, args json.RawMessage) (any, error)
+
+// Tool is a tool registered with a Runner.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  jsonschema.Definition
+	Func        Func
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithMaxIterations sets the maximum number of requests sent to the model in
+// one run. It defaults to DefaultMaxIterations.
+func WithMaxIterations(n int) Option {
+	return func(r *Runner) {
+		r.maxIterations = n
+	}
+}
+
+// WithTimeout sets the maximum duration of a run, including the time spent
+// running tools. A value of zero or less disables the timeout.
+// It defaults to DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Runner) {
+		r.timeout = d
+	}
+}
+
+// Runner runs the tool-calling loop with the Copilot API.
+type Runner struct {
+	client        *copilot.Client
+	tools         []*Tool
+	maxIterations int
+	timeout       time.Duration
+}
+
+// NewRunner returns a new Runner that sends requests with the client.
+func NewRunner(c *copilot.Client, opts ...Option) *Runner {
+	r := &Runner{
+		client:        c,
+		maxIterations: DefaultMaxIterations,
+		timeout:       DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register registers a tool with the runner.
+//
+// Register panics if name is empty or a tool with the same name is already
+// registered with the runner.
+func (r *Runner) Register(name, description string, params jsonschema.Definition, fn Func) {
+	if name == "" {
+		panic("tools: tool name must not be empty")
+	}
+	if r.Tool(name) != nil {
+		panic(fmt.Sprintf("tools: multiple registrations for tool %s", name))
+	}
+	r.tools = append(r.tools, &Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  params,
+		Func:        fn,
+	})
+}
+
+// AddTool registers a tool with typed arguments with the runner. The arguments
+// from the model are decoded into a new T before fn is called.
+func AddTool[T any](r *Runner, name, description string, params jsonschema.Definition, fn func(ctx context.Context, args *T) (any, error)) {
+	r.Register(name, description, params, func(ctx context.Context, raw json.RawMessage) (any, error) {
+		args := new(T)
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, args); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+		}
+		return fn(ctx, args)
+	})
+}
+
+// Tool returns the tool with the given name, or nil if no tool with that name
+// is registered.
+func (r *Runner) Tool(name string) *Tool {
+	for _, t := range r.tools {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Tools returns the registered tools as CompletionsTools.
+func (r *Runner) Tools() []*copilot.CompletionsTool {
+	tools := make([]*copilot.CompletionsTool, 0, len(r.tools))
+	for _, t := range r.tools {
+		tools = append(tools, &copilot.CompletionsTool{
+			Type: "function",
+			Function: copilot.ToolFunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return tools
+}
+
+// Run sends the conversation in req to the model, with the registered tools
+// added to req.Tools. While the model returns tool calls, Run executes them,
+// appends the assistant message and the tool result messages to the conversation,
+// and sends it again. The responses are streamed: the content of the final
+// answer is written to w as deltas (see sse.WriteDelta) with the given id as it
+// arrives, and Run returns the final assistant message.
+//
+// The content of a response is written until the model starts a tool call, so
+// the text the model returns before its tool calls, if any, is written as well.
+//
+// Run doesn't write the stop message, so the caller can keep writing to w; call
+// sse.WriteStop when done.
+func (r *Runner) Run(ctx context.Context, req copilot.CompletionsRequest, w io.Writer, id string) (*copilot.Message, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	req.Tools = append(append([]*copilot.CompletionsTool(nil), req.Tools...), r.Tools()...)
+	req.Messages = append([]*copilot.Message(nil), req.Messages...)
+
+	for i := 0; i < r.maxIterations; i++ {
+		msg, err := r.stream(ctx, req, w, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(msg.ToolCalls) == 0 {
+			return msg, nil
+		}
+
+		req.Messages = append(req.Messages, msg)
+		for _, call := range msg.ToolCalls {
+			req.Messages = append(req.Messages, r.call(ctx, call))
+		}
+	}
+
+	return nil, ErrMaxIterations
+}
+
+// stream sends a streaming completions request, writes the content deltas to w
+// until the model starts a tool call, and returns the accumulated assistant
+// message.
+func (r *Runner) stream(ctx context.Context, req copilot.CompletionsRequest, w io.Writer, id string) (*copilot.Message, error) {
+	s, err := r.client.StreamCompletions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	var acc copilot.CompletionsAccumulator
+	for res, err := range s.All() {
+		var mce *copilot.MalformedChunkError
+		if errors.As(err, &mce) {
+			copilot.GetLogger(ctx).WarnContext(ctx, "skipping malformed completions chunk", "event", mce.Event, "error", mce.Err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		acc.Add(res)
+		if acc.HasToolCalls() {
+			continue
+		}
+		for _, c := range res.Choices {
+			if c.Index == 0 && c.Delta.Content != "" {
+				if err := sse.WriteDelta(w, id, c.Delta.Content); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if !acc.HasToolCalls() && acc.FinishReason() == "" && acc.Content() == "" {
+		return nil, errors.New("tools: completions response has no choices")
+	}
+	return acc.Message(), nil
+}
+
+// call runs the tool call and returns the tool result message.
+func (r *Runner) call(ctx context.Context, call *copilot.ToolCall) *copilot.Message {
+	msg := &copilot.Message{
+		Role:       copilot.ChatRoleTool,
+		ToolCallID: call.ID,
+	}
+
+	if call.Function == nil {
+		msg.Content = "error: tool call has no function"
+		return msg
+	}
+
+	t := r.Tool(call.Function.Name)
+	if t == nil {
+		msg.Content = fmt.Sprintf("error: unknown tool %q", call.Function.Name)
+		return msg
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+
+	if err := t.Parameters.Validate(args); err != nil {
+		// the error lists the invalid arguments so the model can correct them
+		msg.Content = "error: " + err.Error()
+		return msg
+	}
+
+	res, err := t.Func(ctx, args)
+	if err != nil {
+		msg.Content = "error: " + err.Error()
+		return msg
+	}
+
+	switch v := res.(type) {
+	case string:
+		msg.Content = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			msg.Content = "error: failed to marshal tool result: " + err.Error()
+			return msg
+		}
+		msg.Content = string(b)
+	}
+
+	return msg
+}
@@ -0,0 +1,56 @@
+package copilot
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the OpenTelemetry tracer used by this package.
+const tracerName = "github.com/colbylwilliams/copilot-go"
+
+// Span attribute keys set by this package.
+const (
+	AttrThreadID             = "copilot.thread_id"
+	AttrAgent                = "copilot.agent"
+	AttrKeyID                = "copilot.key_id"
+	AttrModel                = "copilot.model"
+	AttrStream               = "copilot.stream"
+	AttrStreamDeltas         = "copilot.stream.deltas"
+	AttrStreamFirstByteMilli = "copilot.stream.time_to_first_byte_ms"
+	AttrEmbeddingsInputs     = "copilot.embeddings.inputs"
+	AttrHTTPStatusCode       = "http.response.status_code"
+)
+
+// WithTracerProvider sets the OpenTelemetry TracerProvider used to create spans
+// for each stage of a request: payload verification, request decoding, session
+// info resolution and the execution of the agent or skill.
+// It defaults to the global TracerProvider.
+//
+// The context passed to the agent or skill carries the execution span, so spans
+// created by the agent (and by ChatCompletions) are its children.
+func WithTracerProvider(tp trace.TracerProvider) HandlerOption {
+	return func(o *handlerOptions) {
+		o.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// tracerFromContext returns a tracer from the TracerProvider of the span in ctx,
+// or from the global TracerProvider if ctx doesn't have a recording span.
+func tracerFromContext(ctx context.Context) trace.Tracer {
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		return span.TracerProvider().Tracer(tracerName)
+	}
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// endSpan records err (if any) on the span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,293 @@
+package copilot
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// PublicKeysURL is the GitHub API endpoint that publishes the public keys
+	// used to sign Copilot payloads.
+	PublicKeysURL = "https://api.github.com/meta/public_keys/copilot_api"
+
+	// DefaultKeyRefreshInterval is how often a KeyVerifier refreshes its keys
+	// in the background, unless configured with WithKeyRefreshInterval.
+	DefaultKeyRefreshInterval = time.Hour
+
+	// DefaultKeyRefetchBackoff is the minimum time between two fetches caused
+	// by an unknown key identifier, unless configured with WithKeyRefetchBackoff.
+	DefaultKeyRefetchBackoff = time.Minute
+
+	// DefaultKeyFetchTimeout is the maximum duration of a fetch of the public
+	// keys, unless configured with WithKeyFetchTimeout.
+	DefaultKeyFetchTimeout = 10 * time.Second
+)
+
+// VerifierOption configures a KeyVerifier.
+type VerifierOption func(*KeyVerifier)
+
+// WithPublicKeysURL sets the URL the public keys are fetched from.
+// It defaults to PublicKeysURL.
+func WithPublicKeysURL(url string) VerifierOption {
+	return func(v *KeyVerifier) {
+		v.url = url
+	}
+}
+
+// WithVerifierHTTPClient sets the http.Client used to fetch the public keys.
+// It defaults to http.DefaultClient.
+func WithVerifierHTTPClient(c *http.Client) VerifierOption {
+	return func(v *KeyVerifier) {
+		v.client = c
+	}
+}
+
+// WithKeyRefreshInterval sets how often the public keys are refreshed in the
+// background. A value of zero or less disables background refreshes.
+func WithKeyRefreshInterval(d time.Duration) VerifierOption {
+	return func(v *KeyVerifier) {
+		v.refreshInterval = d
+	}
+}
+
+// WithKeyRefetchBackoff sets the minimum time between two fetches caused by
+// payloads signed with an unknown key identifier.
+func WithKeyRefetchBackoff(d time.Duration) VerifierOption {
+	return func(v *KeyVerifier) {
+		v.refetchBackoff = d
+	}
+}
+
+// WithKeyFetchTimeout sets the maximum duration of a fetch of the public keys.
+// Requests signed with an unknown key identifier wait for the fetch, so it
+// bounds how long they can be blocked by a slow keys endpoint. A value of zero
+// or less keeps the default.
+func WithKeyFetchTimeout(d time.Duration) VerifierOption {
+	return func(v *KeyVerifier) {
+		if d > 0 {
+			v.fetchTimeout = d
+		}
+	}
+}
+
+// KeyVerifier is a PayloadKeyVerifier that keeps all the public keys GitHub
+// publishes, by key identifier.
+//
+// When a payload is signed with a key identifier it doesn't know, for example
+// right after GitHub rotates its keys, it fetches the keys again (at most once
+// per refetch backoff). It also refreshes the keys in the background.
+type KeyVerifier struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	refetchBackoff  time.Duration
+	fetchTimeout    time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]*ecdsa.PublicKey
+	current   string
+	lastFetch time.Time
+
+	// fetchMu serializes fetches so concurrent requests with an unknown
+	// key identifier only trigger one fetch.
+	fetchMu sync.Mutex
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewKeyVerifier fetches the public keys and returns a new KeyVerifier.
+//
+// Call Close to stop refreshing the keys in the background.
+func NewKeyVerifier(opts ...VerifierOption) (*KeyVerifier, error) {
+	v := &KeyVerifier{
+		url:             PublicKeysURL,
+		client:          http.DefaultClient,
+		refreshInterval: DefaultKeyRefreshInterval,
+		refetchBackoff:  DefaultKeyRefetchBackoff,
+		fetchTimeout:    DefaultKeyFetchTimeout,
+		stop:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	if err := v.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if v.refreshInterval > 0 {
+		go v.refreshLoop()
+	}
+
+	return v, nil
+}
+
+// Verify checks if the payload is valid using the current public key.
+func (v *KeyVerifier) Verify(body []byte, sig string) (bool, error) {
+	return v.VerifyKey(body, sig, "")
+}
+
+// VerifyKey checks if the payload is valid using the public key with the given
+// identifier. If keyID is empty, the current public key is used.
+//
+// If the key identifier is unknown, the keys are fetched again (unless they were
+// fetched within the refetch backoff). If the key is still unknown, VerifyKey
+// returns ErrUnknownPublicKey, and if the fetch fails, an error wrapping
+// ErrPublicKeyFetch.
+func (v *KeyVerifier) VerifyKey(body []byte, sig string, keyID string) (bool, error) {
+	key := v.key(keyID)
+	if key == nil && keyID != "" {
+		if err := v.refetch(); err != nil {
+			return false, fmt.Errorf("%w: %w", ErrPublicKeyFetch, err)
+		}
+		key = v.key(keyID)
+	}
+	if key == nil {
+		return false, fmt.Errorf("%w: %q", ErrUnknownPublicKey, keyID)
+	}
+
+	return verifySignature(key, body, sig)
+}
+
+// Refresh fetches the public keys, replacing the known keys with the
+// published ones.
+func (v *KeyVerifier) Refresh(ctx context.Context) error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	return v.fetch(ctx)
+}
+
+// Close stops refreshing the keys in the background.
+func (v *KeyVerifier) Close() {
+	v.closeOnce.Do(func() {
+		close(v.stop)
+	})
+}
+
+// key returns the public key with the given identifier, or the current
+// public key if keyID is empty.
+func (v *KeyVerifier) key(keyID string) *ecdsa.PublicKey {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if keyID == "" {
+		keyID = v.current
+	}
+	return v.keys[keyID]
+}
+
+// refetch fetches the keys again, unless they were fetched within the
+// refetch backoff.
+func (v *KeyVerifier) refetch() error {
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+
+	v.mu.RLock()
+	recent := time.Since(v.lastFetch) < v.refetchBackoff
+	v.mu.RUnlock()
+
+	if recent {
+		return nil
+	}
+
+	return v.fetch(context.Background())
+}
+
+func (v *KeyVerifier) refreshLoop() {
+	t := time.NewTicker(v.refreshInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-t.C:
+			// keep using the known keys if the refresh fails
+			_ = v.Refresh(context.Background())
+		}
+	}
+}
+
+// fetch fetches the published keys, within the fetch timeout. It must be
+// called with fetchMu held.
+func (v *KeyVerifier) fetch(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, v.fetchTimeout)
+	defer cancel()
+
+	// record the attempt, even if it fails, so a failing endpoint
+	// isn't hit on every request with an unknown key identifier
+	v.mu.Lock()
+	v.lastFetch = time.Now()
+	v.mu.Unlock()
+
+	keys, current, err := fetchPublicKeys(ctx, v.client, v.url)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.current = current
+	v.mu.Unlock()
+
+	return nil
+}
+
+func fetchPublicKeys(ctx context.Context, client *http.Client, url string) (map[string]*ecdsa.PublicKey, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch public keys: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch public keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch public keys: %s", resp.Status)
+	}
+
+	var respBody struct {
+		PublicKeys []struct {
+			Identifier string `json:"key_identifier"`
+			Key        string `json:"key"`
+			IsCurrent  bool   `json:"is_current"`
+		} `json:"public_keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		return nil, "", fmt.Errorf("failed to decode public keys: %w", err)
+	}
+
+	// a key that can't be parsed is skipped, so a single key in an unexpected
+	// format doesn't stop the verification of payloads signed with the others
+	keys := make(map[string]*ecdsa.PublicKey, len(respBody.PublicKeys))
+	var current string
+	for _, pk := range respBody.PublicKeys {
+		k, err := parsePubKey(pk.Key)
+		if err != nil {
+			if pk.IsCurrent {
+				return nil, "", fmt.Errorf("failed to parse current public key %s: %w", pk.Identifier, err)
+			}
+			GetLogger(ctx).WarnContext(ctx, "skipping public key that can't be parsed", "key_id", pk.Identifier, "error", err)
+			continue
+		}
+		keys[pk.Identifier] = k
+		if pk.IsCurrent {
+			current = pk.Identifier
+		}
+	}
+	if current == "" {
+		return nil, "", fmt.Errorf("could not find current public key")
+	}
+
+	return keys, current, nil
+}
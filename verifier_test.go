@@ -0,0 +1,166 @@
+package copilot
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testKey is a signing key published by a keysServer.
+type testKey struct {
+	id   string
+	priv *ecdsa.PrivateKey
+}
+
+func newTestKey(t *testing.T, id string) *testKey {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{id: id, priv: priv}
+}
+
+// sign returns the signature of body, as sent by GitHub.
+func (k *testKey) sign(t *testing.T, body []byte) string {
+	t.Helper()
+	digest := sha256.Sum256(body)
+	r, s, err := ecdsa.Sign(rand.Reader, k.priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := asn1.Marshal(asn1Signature{R: r, S: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// pem returns the public key in PEM format.
+func (k *testKey) pem(t *testing.T) string {
+	t.Helper()
+	b, err := x509.MarshalPKIXPublicKey(&k.priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}))
+}
+
+// keysServer publishes public keys like the GitHub API, and lets tests rotate
+// them.
+type keysServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+
+	mu   sync.Mutex
+	keys []map[string]any
+}
+
+func newKeysServer(t *testing.T) *keysServer {
+	t.Helper()
+	s := &keysServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"public_keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// publish replaces the published keys with current and the others.
+func (s *keysServer) publish(t *testing.T, current *testKey, others ...*testKey) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = []map[string]any{{"key_identifier": current.id, "key": current.pem(t), "is_current": true}}
+	for _, k := range others {
+		s.keys = append(s.keys, map[string]any{"key_identifier": k.id, "key": k.pem(t), "is_current": false})
+	}
+}
+
+func newTestVerifier(t *testing.T, s *keysServer, backoff time.Duration) *KeyVerifier {
+	t.Helper()
+	v, err := NewKeyVerifier(WithPublicKeysURL(s.URL), WithKeyRefreshInterval(0), WithKeyRefetchBackoff(backoff))
+	if err != nil {
+		t.Fatalf("NewKeyVerifier() error = %v", err)
+	}
+	t.Cleanup(v.Close)
+	return v
+}
+
+func TestKeyVerifierRefetchesRotatedKeys(t *testing.T) {
+	old, rotated := newTestKey(t, "old"), newTestKey(t, "new")
+	s := newKeysServer(t)
+	s.publish(t, old)
+	v := newTestVerifier(t, s, 0)
+
+	body := []byte(`{"messages":[]}`)
+	if ok, err := v.VerifyKey(body, old.sign(t, body), old.id); !ok || err != nil {
+		t.Fatalf("VerifyKey(old) = %v, %v, want true, nil", ok, err)
+	}
+
+	s.publish(t, rotated, old)
+	if ok, err := v.VerifyKey(body, rotated.sign(t, body), rotated.id); !ok || err != nil {
+		t.Fatalf("VerifyKey(new) = %v, %v, want true, nil", ok, err)
+	}
+	if got := s.fetches.Load(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+
+	// the current key is the rotated one
+	if ok, err := v.Verify(body, rotated.sign(t, body)); !ok || err != nil {
+		t.Errorf("Verify() = %v, %v, want true, nil", ok, err)
+	}
+	if got := s.fetches.Load(); got != 2 {
+		t.Errorf("fetches = %d, want no fetch for known keys", got)
+	}
+}
+
+func TestKeyVerifierRefetchBackoff(t *testing.T) {
+	k := newTestKey(t, "current")
+	s := newKeysServer(t)
+	s.publish(t, k)
+	v := newTestVerifier(t, s, time.Hour)
+
+	// the keys were just fetched, so an unknown key isn't fetched again
+	body := []byte(`{"messages":[]}`)
+	for range 3 {
+		_, err := v.VerifyKey(body, k.sign(t, body), "unknown")
+		if !errors.Is(err, ErrUnknownPublicKey) {
+			t.Fatalf("VerifyKey(unknown) error = %v, want ErrUnknownPublicKey", err)
+		}
+	}
+	if got := s.fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
+
+func TestKeyVerifierSkipsInvalidKeys(t *testing.T) {
+	k := newTestKey(t, "current")
+	s := newKeysServer(t)
+	s.publish(t, k)
+	s.mu.Lock()
+	s.keys = append(s.keys, map[string]any{"key_identifier": "invalid", "key": "not a key", "is_current": false})
+	s.mu.Unlock()
+	v := newTestVerifier(t, s, 0)
+
+	body := []byte(`{"messages":[]}`)
+	if ok, err := v.VerifyKey(body, k.sign(t, body), k.id); !ok || err != nil {
+		t.Errorf("VerifyKey() = %v, %v, want true, nil", ok, err)
+	}
+}